@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stevebargelt/Dockhand/jenkins"
+	"github.com/stevebargelt/Dockhand/standards"
+)
+
+// verifyAgent checks the candidate can act as a Jenkins docker slave and, when asked to,
+// proves it by making an agent connection
+func verifyAgent(c *candidate, report *standards.Report) {
+
+	options := standards.AgentOptions{
+		Type:           cfg.Agent.Type,
+		MinJavaVersion: cfg.Agent.MinJavaVersion,
+		MaxJavaVersion: cfg.Agent.MaxJavaVersion,
+		User:           cfg.Agent.User,
+		Home:           cfg.Agent.Home,
+	}
+	info := standards.CheckAgent(options, c, report)
+	if !cfg.Agent.Handshake {
+		return
+	}
+
+	timeout := time.Duration(cfg.Agent.HandshakeTimeout) * time.Second
+	switch info.Type {
+	case standards.AgentSSH:
+		banner, err := sshHandshake(c.containerInfo, timeout)
+		if err != nil {
+			report.Add("agent handshake", false, "%v", err)
+		} else {
+			report.Add("agent handshake", true, "%s", banner)
+		}
+	case standards.AgentJNLP:
+		protocol, err := jnlpHandshake(c.containerInfo, info.Launcher, timeout)
+		if err != nil {
+			report.Add("agent handshake", false, "%v", err)
+		} else {
+			report.Add("agent handshake", true, "agent connected with %s", protocol)
+		}
+	default:
+		report.Add("agent handshake", false, "skipped, the image has no agent to start")
+	}
+}
+
+// sshPort is sshd's port in an ssh slave, the test container publishes it on the Docker host
+// for the handshake
+const sshPort = "22/tcp"
+
+// agentPorts are the ports of the test container the agent handshake connects to
+func agentPorts() []string {
+
+	if cfg.Agent.Verify && cfg.Agent.Handshake && cfg.Agent.Type != standards.AgentJNLP {
+		return []string{sshPort}
+	}
+	return nil
+}
+
+// sshHandshake connects to sshd in the running test container the way the Jenkins
+// ssh launcher would, through the port published on the Docker host, and returns the
+// server's identification string
+func sshHandshake(containerInfo types.ContainerJSON, timeout time.Duration) (string, error) {
+
+	address, err := dockerClient.PublishedAddress(containerInfo, sshPort)
+	if err != nil {
+		return "", err
+	}
+
+	var conn net.Conn
+	deadline := time.Now().Add(timeout)
+	// sshd may still be starting up
+	for {
+		conn, err = net.DialTimeout("tcp", address, 5*time.Second)
+		if err == nil || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+	if err != nil {
+		return "", errors.New("cannot connect to sshd on " + address + ": " + err.Error())
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(deadline.Add(5 * time.Second))
+	banner, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return "", errors.New("sshd on " + address + " did not identify itself: " + err.Error())
+	}
+	banner = strings.TrimSpace(banner)
+	if !strings.HasPrefix(banner, "SSH-2.0-") {
+		return "", errors.New("unexpected identification from " + address + ": " + banner)
+	}
+	return banner, nil
+}
+
+// jnlpHandshake starts the image's JNLP agent against a stub master and waits for it to connect
+func jnlpHandshake(containerInfo types.ContainerJSON, launcher string, timeout time.Duration) (string, error) {
+
+	host := cfg.Agent.StubHost
+	if host == "" && containerInfo.NetworkSettings != nil {
+		host = containerInfo.NetworkSettings.Gateway
+	}
+	if host == "" {
+		return "", errors.New("no address for the agent to reach dockhand on, set agent.stubHost")
+	}
+
+	stub, err := jenkins.NewAgentStub("", host)
+	if err != nil {
+		return "", err
+	}
+	defer stub.Close()
+
+	cmd := []string{launcher, "-url", stub.URL, stub.Secret, stub.Name}
+	if strings.HasSuffix(launcher, ".jar") {
+		cmd = []string{"java", "-jar", launcher, "-jnlpUrl", stub.JNLPURL(), "-secret", stub.Secret}
+	}
+	fmt.Fprintln(stdout, "Starting a JNLP agent against the stub master at", stub.URL, "...")
+	ctx, cancel := withTimeout(cfg.Timeouts.Docker)
+	defer cancel()
+	agentID, err := dockerClient.StartCommand(ctx, containerInfo.Image, cmd)
+	if err != nil {
+		return "", err
+	}
+	defer removeContainer(agentID)
+
+	return stub.WaitForHandshake(timeout)
+}
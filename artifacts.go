@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// tailLines is how much of the test container's output verify prints when it fails
+const tailLines = 20
+
+// artifactDir is where this run saves what verify found out: a directory per run under cfg.Artifacts
+func artifactDir() string {
+	return filepath.Join(cfg.Artifacts, runID)
+}
+
+// saveArtifacts writes files (name to contents) to the run's artifact directory.
+// Nothing is saved when no artifacts directory is configured.
+func saveArtifacts(files map[string][]byte) error {
+
+	if cfg.Artifacts == "" {
+		return nil
+	}
+	dir := artifactDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), contents, 0644); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(stdout, "Saved verification artifacts to", dir)
+	return nil
+}
+
+// printTail prints the last lines of a container output stream
+func printTail(name string, output []byte) {
+
+	output = bytes.TrimRight(output, "\n")
+	if len(output) == 0 {
+		fmt.Fprintln(stdout, "No", name, "output.")
+		return
+	}
+	lines := bytes.Split(output, []byte("\n"))
+	if len(lines) > tailLines {
+		lines = lines[len(lines)-tailLines:]
+		fmt.Fprintln(stdout, "Last", tailLines, "lines of", name+":")
+	} else {
+		fmt.Fprintln(stdout, name+":")
+	}
+	for _, line := range lines {
+		fmt.Fprintf(stdout, "  %s\n", line)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stevebargelt/Dockhand/config"
+)
+
+func TestSaveArtifacts(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "artifacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg = &config.Config{Artifacts: dir}
+	stdout = ioutil.Discard
+	if err := saveArtifacts(map[string][]byte{"report.txt": []byte("1 checks, 0 failed\n")}); err != nil {
+		t.Fatal(err)
+	}
+	contents, err := ioutil.ReadFile(filepath.Join(dir, runID, "report.txt"))
+	if err != nil || string(contents) != "1 checks, 0 failed\n" {
+		t.Errorf("report.txt = %q, %v", contents, err)
+	}
+
+	cfg = &config.Config{}
+	if err := saveArtifacts(map[string][]byte{"report.txt": nil}); err != nil {
+		t.Errorf("saving without an artifacts directory: %v", err)
+	}
+}
+
+func TestPrintTail(t *testing.T) {
+
+	var out bytes.Buffer
+	stdout = &out
+
+	var output bytes.Buffer
+	for i := 1; i <= 25; i++ {
+		fmt.Fprintf(&output, "line %d\n", i)
+	}
+	printTail("stdout", output.Bytes())
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != tailLines+1 || lines[1] != "  line 6" || lines[tailLines] != "  line 25" {
+		t.Errorf("tail:\n%s", out.String())
+	}
+
+	out.Reset()
+	printTail("stderr", nil)
+	if out.String() != "No stderr output.\n" {
+		t.Errorf("empty tail %q", out.String())
+	}
+}
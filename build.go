@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/stevebargelt/Dockhand/config"
+	"github.com/stevebargelt/Dockhand/docker"
+)
+
+// buildContext returns the configured build context, falling back to the repo url
+func buildContext() string {
+
+	if cfg.Build.Context != "" {
+		return cfg.Build.Context
+	}
+	return cfg.RepoURL
+}
+
+// buildOptions turns the build config into docker.BuildOptions. The returned func closes
+// the tar file when the context is one.
+func buildOptions() (docker.BuildOptions, func(), error) {
+
+	noop := func() {}
+	buildArgs, err := keyValues(cfg.Build.Args)
+	if err != nil {
+		return docker.BuildOptions{}, noop, err
+	}
+	labels, err := imageLabels()
+	if err != nil {
+		return docker.BuildOptions{}, noop, err
+	}
+
+	options := docker.BuildOptions{
+		Dockerfile: cfg.Build.Dockerfile,
+		BuildArgs:  buildArgs,
+		Target:     cfg.Build.Target,
+		Labels:     labels,
+		CacheFrom:  cfg.Build.CacheFrom,
+		NoCache:    cfg.Build.NoCache,
+		Pull:       cfg.Build.Pull,
+	}
+
+	context := buildContext()
+	switch {
+	case config.IsGitURL(context):
+		options.Remote = context
+		options.Ref = cfg.Build.Ref
+		options.Subdir = cfg.Build.Subdir
+		return options, noop, nil
+	case context == "-":
+		options.Tar = os.Stdin
+		return options, noop, nil
+	case strings.HasSuffix(context, ".tar") || strings.HasSuffix(context, ".tar.gz") || strings.HasSuffix(context, ".tgz"):
+		f, err := os.Open(context)
+		if err != nil {
+			return options, noop, err
+		}
+		options.Tar = f
+		return options, func() { f.Close() }, nil
+	}
+	options.Dir = context
+	return options, noop, nil
+}
+
+// imageLabels are the labels every image dockhand builds carries so it can be traced back
+// to its team, Jenkins label and source. Labels from the build config are added to them.
+func imageLabels() (map[string]string, error) {
+
+	extra, err := keyValues(cfg.Build.Labels)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := map[string]string{
+		"dockhand.label":   cfg.Label,
+		"dockhand.version": VERSION,
+	}
+	if cfg.Team != "" {
+		labels["dockhand.team"] = cfg.Team
+	}
+	if commit := gitCommit(); commit != "" {
+		labels["dockhand.git-commit"] = commit
+	}
+	for name, value := range extra {
+		labels[name] = value
+	}
+	return labels, nil
+}
+
+// keyValues turns a list of KEY=VALUE settings into a map
+func keyValues(list []string) (map[string]string, error) {
+
+	m := make(map[string]string, len(list))
+	for _, kv := range list {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.New("expected KEY=VALUE, got " + kv)
+		}
+		m[parts[0]] = parts[1]
+	}
+	return m, nil
+}
+
+var commitHash = regexp.MustCompile("^[0-9a-f]{7,40}$")
+
+// gitCommit returns the configured commit of the build source, or asks git when
+// building from a local directory
+func gitCommit() string {
+
+	if cfg.Build.GitCommit != "" {
+		return cfg.Build.GitCommit
+	}
+	context := buildContext()
+	if config.IsGitURL(context) {
+		// a branch or tag name says little about the source, only a commit hash is recorded
+		if commitHash.MatchString(cfg.Build.Ref) {
+			return cfg.Build.Ref
+		}
+		return ""
+	}
+	out, err := exec.Command("git", "-C", context, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// runCtx is cancelled when dockhand is interrupted, which aborts the Docker operations in flight
+var runCtx, cancelRun = context.WithCancel(context.Background())
+
+// interrupted holds the signal that cancelled the run, if any
+var interrupted atomic.Value
+
+// cleanupTimeout is how long removing the run's containers may take
+const cleanupTimeout = 2 * time.Minute
+
+// withTimeout returns a context for an operation that may take seconds (no limit when 0)
+// and is cancelled with the run
+func withTimeout(seconds int) (context.Context, context.CancelFunc) {
+
+	if seconds <= 0 {
+		return context.WithCancel(runCtx)
+	}
+	return context.WithTimeout(runCtx, time.Duration(seconds)*time.Second)
+}
+
+// cleanupContext is for removing what the run created, which has to work after the run was cancelled
+func cleanupContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), cleanupTimeout)
+}
+
+// exitCleanup makes sure dockhand removes what it created on the Docker host however it
+// exits: after the command, on a panic and after an interrupt
+var exitCleanup sync.Once
+
+func cleanupOnExit() {
+
+	exitCleanup.Do(func() {
+		if dockerClient == nil {
+			return
+		}
+		ctx, cancel := cleanupContext()
+		defer cancel()
+		if err := dockerClient.RemoveContainers(ctx); err != nil {
+			fmt.Fprintln(stderr, "Error removing test containers:", err, "- run dockhand cleanup")
+		}
+	})
+}
+
+// handleSignals cancels the run on SIGINT or SIGTERM so the command stops and cleans up.
+// A second signal exits straight away.
+func handleSignals() {
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		interrupted.Store(sig)
+		fmt.Fprintln(stderr, "\nReceived", sig, "- stopping and removing test containers, again to exit now...")
+		cancelRun()
+
+		sig = <-signals
+		fmt.Fprintln(stderr, "\nReceived", sig, "again - exiting, run dockhand cleanup to remove test containers")
+		os.Exit(signalExitCode(sig))
+	}()
+}
+
+// signalExitCode is the exit code of a process killed by sig, as shells report it
+func signalExitCode(sig os.Signal) int {
+
+	if sig == syscall.SIGTERM {
+		return 128 + int(syscall.SIGTERM)
+	}
+	return 128 + int(syscall.SIGINT)
+}
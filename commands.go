@@ -0,0 +1,784 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/bndr/gojenkins"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/stevebargelt/Dockhand/docker"
+	"github.com/stevebargelt/Dockhand/jenkins"
+	"github.com/stevebargelt/Dockhand/standards"
+	"gopkg.in/yaml.v2"
+)
+
+func buildCommand() error {
+
+	if err := connectToDockerHost(); err != nil {
+		return err
+	}
+
+	options, closeContext, err := buildOptions()
+	if err != nil {
+		return err
+	}
+	defer closeContext()
+
+	fmt.Fprintln(stdout, "Building", cfg.ImageName, "from", buildContext(), "...")
+	ctx, cancel := withTimeout(cfg.Timeouts.Build)
+	defer cancel()
+	imageID, err := dockerClient.BuildDockerImage(ctx, cfg.ImageName, options, docker.TerminalProgress(stdout))
+	if err != nil {
+		fmt.Fprintln(stdout, "Build failed.")
+		return err
+	}
+	fmt.Fprintln(stdout, "Build success! Image ID:", imageID[7:19])
+	return nil
+}
+
+func pushCommand() error {
+
+	if err := connectToDockerHost(); err != nil {
+		return err
+	}
+
+	if err := cfg.ResolveRegistrySecrets(secretResolver); err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, "Pushing", cfg.ImageName, "to registry", cfg.RegistryURL, "...")
+	ctx, cancel := withTimeout(cfg.Timeouts.Push)
+	defer cancel()
+	digest, err := dockerClient.PushDockerImage(ctx, cfg.ImageName, cfg.RegistryUser, cfg.RegistryPassword, cfg.RegistryURL, docker.TerminalProgress(stdout))
+	if err != nil {
+		fmt.Fprintln(stdout, "Push failed.")
+		return err
+	}
+	pushedDigest = digest
+	fmt.Fprintln(stdout, "Push success! Digest:", digest)
+	return nil
+}
+
+func verifyCommand() (err error) {
+
+	fmt.Fprint(stdout, "\n********************\n Docker Image and Container Verification Process\n********************\n")
+	if err := connectToDockerHost(); err != nil {
+		return err
+	}
+
+	if err := pullDockerImage(); err != nil {
+		return err
+	}
+	newContainer, err := createDockerContainer()
+	if err != nil {
+		return err
+	}
+	// the test container goes whatever happens below
+	defer func() {
+		if removeErr := removeDockerContainer(newContainer); removeErr != nil && err == nil {
+			err = removeErr
+		}
+	}()
+
+	if err := startDockerContainer(newContainer); err != nil {
+		return err
+	}
+	testResult, err := testDockerContainer(newContainer)
+	if err != nil {
+		return err
+	}
+	if !testResult {
+		return errors.New("container verification failed for " + cfg.ImageName)
+	}
+
+	//TODO: If tests pass we want to pull the image to all hosts in SWARM to save time at build
+	//		(advice from Maxfield Stewart of Riot Games )
+
+	return nil
+}
+
+func registerCommand() error {
+
+	fmt.Fprint(stdout, "\n\n********************\nAdd Build To Jenkins\n********************\n")
+
+	image := templateImage()
+	fmt.Fprint(stdout, "Checking that label ", cfg.Label, " is unique...")
+	if err := connectToJenkinsScripts(); err != nil {
+		return err
+	}
+	labelIsUnique, labels, err := jenkins.CheckLabelIsUnique(jenkinsScripts, cfg.CloudName, cfg.Label, jenkins.LabelMatch(cfg.LabelMatch))
+	if err != nil {
+		fmt.Fprintln(stdout, " failed.")
+		return err
+	}
+	registered := false
+	if !labelIsUnique {
+		// a template for the same label and image is what an earlier run that failed later on left behind
+		existing, found, err := jenkins.TemplateImage(jenkinsScripts, cfg.CloudName, cfg.Label)
+		if err != nil {
+			fmt.Fprintln(stdout, " failed.")
+			return err
+		}
+		registered = found && existing == image
+	}
+	switch {
+	case registered:
+		fmt.Fprintln(stdout, " it is already registered for", image+", continuing.")
+	case !labelIsUnique:
+		fmt.Fprintln(stdout, " it is NOT unique! build labels must be unique.")
+		fmt.Fprintln(stdout, "Labels in", cfg.CloudName+":", strings.Join(labels, ", "))
+		fmt.Fprintln(stdout, "Try a label that is free, e.g.", jenkins.SuggestLabel(cfg.Label, labels, jenkins.LabelMatch(cfg.LabelMatch)))
+		return fmt.Errorf("the label %s is not unique in Jenkins at %s, cannot create this build", cfg.Label, cfg.JenkinsURL)
+	default:
+		fmt.Fprintln(stdout, " it is unique, continuing.")
+
+		fmt.Fprint(stdout, "Creating docker slave template for ", image, " in ", cfg.CloudName, "... ")
+		slaveTemplateCreated, err := jenkins.CreateDockerTemplate(jenkinsScripts, cfg.CloudName, cfg.Template.DockerTemplate(cfg.Label, image))
+		if err != nil {
+			fmt.Fprintln(stdout, "failed.")
+			return err
+		}
+		if !slaveTemplateCreated {
+			fmt.Fprintln(stdout, "failed.")
+			return errors.New("Jenkins did not create the docker slave template for " + cfg.Label)
+		}
+		fmt.Fprintln(stdout, "success!")
+	}
+
+	if err := connectToJenkins(); err != nil {
+		return err
+	}
+
+	fmt.Fprint(stdout, "Adding jenkins job... ")
+	jobExists, err := jenkins.JobExists(jenkinsHTTP, jobName())
+	if err != nil {
+		fmt.Fprintln(stdout, "failed.")
+		return err
+	}
+	var newJob *gojenkins.Job
+	if jobExists {
+		newJob, err = jenkinsClient.GetJob(jobName())
+	} else {
+		newJob, err = jenkinsClient.CreateJob(jobConfig(cfg.RepoURL), jobName())
+	}
+	if err != nil {
+		fmt.Fprintln(stdout, "failed.")
+		return err
+	}
+	if jobExists {
+		fmt.Fprintln(stdout, newJob.GetName(), "already exists, continuing.")
+	} else {
+		fmt.Fprintln(stdout, "success! Created", newJob.GetName(), ".")
+	}
+
+	fmt.Fprint(stdout, "Kicking first build... ")
+	m := make(map[string]string)
+	jobResult, err := newJob.InvokeSimple(m)
+	if err != nil {
+		fmt.Fprintln(stdout, "failed.")
+		return err
+	}
+	if jobResult == true {
+		fmt.Fprintln(stdout, "build Success.")
+	} else {
+		fmt.Fprintln(stdout, "build fail.")
+	}
+	return nil
+}
+
+func deregisterCommand() error {
+
+	if err := connectToJenkins(); err != nil {
+		return err
+	}
+
+	// the job may be gone already, or never created by a register that failed, the template is removed anyway
+	fmt.Fprint(stdout, "Deleting jenkins job ", jobName(), "... ")
+	jobExists, err := jenkins.JobExists(jenkinsHTTP, jobName())
+	if err != nil {
+		fmt.Fprintln(stdout, "failed.")
+		return err
+	}
+	if jobExists {
+		if _, err := jenkinsClient.DeleteJob(jobName()); err != nil {
+			fmt.Fprintln(stdout, "failed.")
+			return err
+		}
+		fmt.Fprintln(stdout, "success.")
+	} else {
+		fmt.Fprintln(stdout, "not found, skipping.")
+	}
+
+	if err := connectToJenkinsScripts(); err != nil {
+		return err
+	}
+	fmt.Fprint(stdout, "Removing docker slave template ", cfg.Label, " from ", cfg.CloudName, "... ")
+	removed, err := jenkins.RemoveDockerTemplate(jenkinsScripts, cfg.CloudName, cfg.Label)
+	if err != nil {
+		fmt.Fprintln(stdout, "failed.")
+		return err
+	}
+	if !removed {
+		fmt.Fprintln(stdout, "failed.")
+		return errors.New("Jenkins did not remove the docker slave template for " + cfg.Label)
+	}
+	fmt.Fprintln(stdout, "success.")
+	return nil
+}
+
+func runCommand() error {
+
+	for _, step := range []func() error{buildCommand, pushCommand, verifyCommand, registerCommand} {
+		if err := step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func cleanupCommand() error {
+
+	if err := connectToDockerHost(); err != nil {
+		return err
+	}
+
+	labels, which := map[string]string{testLabel: cfg.Label}, "label "+cfg.Label
+	if *allLabels {
+		labels, which = nil, "all labels"
+	}
+	fmt.Fprint(stdout, "Looking for test continers for ", which, "...")
+	ctx, cancel := withTimeout(cfg.Timeouts.Docker)
+	defer cancel()
+	leftovers, err := dockerClient.ManagedContainers(ctx, labels)
+	if err != nil {
+		fmt.Fprintln(stdout, " failed.")
+		return err
+	}
+	if len(leftovers) == 0 {
+		fmt.Fprintln(stdout, " nothing to clean up.")
+		return nil
+	}
+	fmt.Fprintln(stdout, " found", len(leftovers))
+
+	failed := 0
+	for _, leftover := range leftovers {
+		fmt.Fprint(stdout, "Removing continer ", leftover.ID[0:11], " ", strings.Join(leftover.Names, ","), " (label ", leftover.Labels[testLabel],
+			", run ", leftover.Labels[runIDLabel], " by ", leftover.Labels[ownerLabel], " started ", leftover.Labels[startedLabel], ", ", leftover.Status, ")...")
+		if err := removeContainer(leftover.ID); err != nil && !docker.IsErrNotFound(err) {
+			fmt.Fprintln(stdout, " failed:", err)
+			failed++
+			continue
+		}
+		fmt.Fprintln(stdout, " success.")
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d test containers could not be removed", failed, len(leftovers))
+	}
+	return nil
+}
+
+func statusCommand() error {
+
+	if err := connectToDockerHost(); err != nil {
+		return err
+	}
+
+	fmt.Fprint(stdout, "Image ", cfg.ImageName, ": ")
+	ctx, cancel := withTimeout(cfg.Timeouts.Docker)
+	defer cancel()
+	image, err := dockerClient.ImageInspect(ctx, cfg.ImageName)
+	switch {
+	case docker.IsErrNotFound(err):
+		fmt.Fprintln(stdout, "not present on the docker host.")
+	case err != nil:
+		return err
+	default:
+		fmt.Fprintln(stdout, "present, ID", image.ID[7:19])
+	}
+
+	fmt.Fprint(stdout, "Label ", cfg.Label, " in ", cfg.CloudName, ": ")
+	if err := connectToJenkinsScripts(); err != nil {
+		return err
+	}
+	labelIsUnique, _, err := jenkins.CheckLabelIsUnique(jenkinsScripts, cfg.CloudName, cfg.Label, jenkins.LabelMatch(cfg.LabelMatch))
+	if err != nil {
+		return err
+	}
+	if labelIsUnique {
+		fmt.Fprintln(stdout, "not registered.")
+	} else {
+		fmt.Fprintln(stdout, "registered.")
+	}
+
+	if err := connectToJenkins(); err != nil {
+		return err
+	}
+	fmt.Fprint(stdout, "Job ", jobName(), ": ")
+	if _, err := jenkinsClient.GetJob(jobName()); err != nil {
+		fmt.Fprintln(stdout, "not found.")
+	} else {
+		fmt.Fprintln(stdout, "exists.")
+	}
+	return nil
+}
+
+func scriptsCommand() error {
+
+	for _, script := range jenkins.Scripts {
+		fmt.Fprintf(os.Stdout, "// Scriptler ID %s.groovy, parameters: %s\n%s\n", script.Name, strings.Join(script.Params, ", "), script.Source)
+	}
+	return nil
+}
+
+func configShowCommand() error {
+
+	out, err := yaml.Marshal(cfg.Redacted())
+	if err != nil {
+		return err
+	}
+	// not prefixed with the run ID so the output stays valid YAML
+	fmt.Fprint(os.Stdout, string(out))
+	return cfg.Validate()
+}
+
+// templateImage returns the image reference to register with Jenkins. The digest of the
+// pushed image is used where known so the template does not change when the tag moves.
+func templateImage() string {
+
+	if pushedDigest != "" {
+		return docker.DigestReference(cfg.ImageName, pushedDigest)
+	}
+	if err := connectToDockerHost(); err == nil {
+		ctx, cancel := withTimeout(cfg.Timeouts.Docker)
+		defer cancel()
+		digest, err := dockerClient.ImageDigest(ctx, cfg.ImageName)
+		if err == nil && digest != "" {
+			return docker.DigestReference(cfg.ImageName, digest)
+		}
+	}
+	fmt.Fprintln(stdout, "No pushed digest found for", cfg.ImageName, "registering the tag instead.")
+	return cfg.ImageName
+}
+
+func connectToDockerHost() error {
+
+	if dockerClient != nil {
+		return nil
+	}
+
+	var err error
+	fmt.Fprint(stdout, "Connecting to dockerhost... ")
+	dockerClient, err = docker.New(docker.Options{
+		Host:      cfg.DockerHostURL,
+		TLSFolder: cfg.DockerTLSFolder,
+		CertFile:  cfg.CertFile,
+		KeyFile:   cfg.KeyFile,
+		CAFile:    cfg.CAFile,
+		TLSVerify: cfg.DockerTLSVerify,
+		UserAgent: "dockhand/" + VERSION + " (" + COMMIT + ")",
+	})
+	if err != nil {
+		fmt.Fprintln(stdout, "failed.")
+		return err
+	}
+	if dockerClient == nil {
+		fmt.Fprintln(stdout, "failed.")
+		return errors.New("docker client is nil")
+	}
+	ctx, cancel := withTimeout(cfg.Timeouts.Docker)
+	defer cancel()
+	if err := dockerClient.NegotiateAPIVersion(ctx); err != nil {
+		fmt.Fprintln(stdout, "failed.")
+		dockerClient = nil
+		return err
+	}
+	dockerClient.Labels = map[string]string{
+		testLabel:    cfg.Label,
+		ownerLabel:   owner(),
+		runIDLabel:   runID,
+		startedLabel: runStarted.Format(time.RFC3339),
+	}
+	fmt.Fprintln(stdout, "success! (Docker API", dockerClient.APIVersion()+")")
+	return nil
+}
+
+func connectToJenkins() error {
+
+	if jenkinsClient != nil {
+		return nil
+	}
+
+	fmt.Fprint(stdout, "Connecting to Jenkins... ")
+	client, err := jenkinsHTTPClient()
+	if err != nil {
+		fmt.Fprintln(stdout, "failed.")
+		return err
+	}
+	jenkinsClient, err = jenkins.InitClient(client)
+	if err != nil {
+		fmt.Fprintln(stdout, "failed.")
+		return err
+	}
+	if jenkinsClient == nil {
+		fmt.Fprintln(stdout, "failed.")
+		return errors.New("Jenkins object is nil")
+	}
+	fmt.Fprintln(stdout, "success. Connected.")
+	return nil
+}
+
+// connectToJenkinsScripts sets up the backend that runs dockhand's scripts on Jenkins
+func connectToJenkinsScripts() error {
+
+	if jenkinsScripts != nil {
+		return nil
+	}
+	client, err := jenkinsHTTPClient()
+	if err != nil {
+		return err
+	}
+	jenkinsScripts, err = jenkins.NewBackend(cfg.JenkinsScripts, client)
+	return err
+}
+
+// jenkinsHTTPClient returns the client shared by the gojenkins and script calls, so they
+// share the crumb and the session
+func jenkinsHTTPClient() (*jenkins.Client, error) {
+
+	if jenkinsHTTP != nil {
+		return jenkinsHTTP, nil
+	}
+	if err := cfg.ResolveJenkinsSecrets(secretResolver); err != nil {
+		return nil, err
+	}
+	var err error
+	jenkinsHTTP, err = jenkins.NewClient(cfg.JenkinsURL, jenkins.Auth{
+		Username:    cfg.JenkinsUser,
+		Password:    cfg.JenkinsPassword,
+		APIToken:    cfg.JenkinsAPIToken,
+		BearerToken: cfg.JenkinsBearerToken,
+		CertFile:    cfg.JenkinsCertFile,
+		KeyFile:     cfg.JenkinsKeyFile,
+		CAFile:      cfg.JenkinsCAFile,
+	})
+	return jenkinsHTTP, err
+}
+
+func pullDockerImage() error {
+
+	if err := cfg.ResolveRegistrySecrets(secretResolver); err != nil {
+		return err
+	}
+	fmt.Fprint(stdout, "Pulling ", cfg.ImageName, " from registry ", cfg.RegistryURL)
+	ctx, cancel := withTimeout(cfg.Timeouts.Pull)
+	defer cancel()
+	newImage, pullErr, err := dockerClient.GetDockerImage(ctx, cfg.ImageName, cfg.RegistryUser, cfg.RegistryPassword, cfg.RegistryURL)
+	if err != nil {
+		fmt.Fprintln(stdout, " failed.")
+		return err
+	}
+	if pullErr != nil {
+		fmt.Fprintln(stdout, " failed:", pullErr)
+		fmt.Fprintln(stdout, "Using the image found on the docker host instead, ID", newImage.ID[7:19])
+		return nil
+	}
+	fmt.Fprintln(stdout, " success!\nPulled Image ID:", newImage.ID[7:19])
+	return nil
+}
+
+func createDockerContainer() (container.ContainerCreateCreatedBody, error) {
+
+	fmt.Fprint(stdout, "Creating continer from ", cfg.ImageName, "...")
+	ctx, cancel := withTimeout(cfg.Timeouts.Docker)
+	defer cancel()
+	newContianer, err := dockerClient.CreateContainer(ctx, testContainerSpec())
+	if err != nil {
+		fmt.Fprintln(stdout, " failed.")
+		return container.ContainerCreateCreatedBody{}, err
+	}
+	fmt.Fprintln(stdout, " success.\nContiner ID: ", newContianer.ID[0:11])
+	return *newContianer, nil
+
+}
+
+func startDockerContainer(container container.ContainerCreateCreatedBody) error {
+
+	fmt.Fprint(stdout, "Starting continer ", container.ID[0:11], "...")
+	ctx, cancel := withTimeout(cfg.Timeouts.Docker)
+	defer cancel()
+	err := dockerClient.StartContainer(ctx, container.ID)
+	if err != nil {
+		fmt.Fprintln(stdout, "failed.")
+		return err
+	}
+	fmt.Fprintln(stdout, "success.")
+	return nil
+}
+
+func testDockerContainer(container container.ContainerCreateCreatedBody) (bool, error) {
+
+	fmt.Fprintln(stdout, "Testing continer", container.ID[0:11], "...")
+	report := &standards.Report{}
+
+	// a slave container keeps running, one that exits within startupWait is judged by how it exited
+	startupWait := time.Duration(cfg.StartupWait) * time.Second
+	waited, err := dockerClient.WaitContainer(runCtx, container.ID, startupWait)
+	switch err.(type) {
+	case nil:
+		switch {
+		case waited.OOMKilled:
+			report.Add("container starts", false, "ran out of memory after %s", waited.Duration)
+		case waited.StatusCode != 0:
+			report.Add("container starts", false, "exited with code %d after %s", waited.StatusCode, waited.Duration)
+		default:
+			report.Add("container starts", true, "exited with code 0 after %s", waited.Duration)
+		}
+	case *docker.WaitTimeoutError:
+		report.Add("container starts", true, "still running after %s", startupWait)
+	default:
+		return false, err
+	}
+
+	ctx, cancel := withTimeout(cfg.Timeouts.Docker)
+	defer cancel()
+	containerInfo, err := dockerClient.ContainerInspect(ctx, container.ID)
+	if err != nil {
+		return false, err
+	}
+
+	spec, err := loadStandards()
+	if err != nil {
+		return false, err
+	}
+	c := &candidate{containerInfo: containerInfo}
+	if spec != nil {
+		standards.Run(spec, c, report)
+	}
+	if cfg.Agent.Verify {
+		verifyAgent(c, report)
+	}
+
+	report.Print(stdout)
+	saveVerification(container.ID, report)
+	return report.Passed(), nil
+}
+
+// saveVerification saves the report and the test container's output as artifacts
+// and prints the end of the output when verification failed
+func saveVerification(containerID string, report *standards.Report) {
+
+	var reportText bytes.Buffer
+	report.Print(&reportText)
+	files := map[string][]byte{"report.txt": reportText.Bytes()}
+
+	ctx, cancel := withTimeout(cfg.Timeouts.Docker)
+	defer cancel()
+	containerStdout, containerStderr, err := dockerClient.ContainerLogs(ctx, containerID)
+	if err != nil {
+		fmt.Fprintln(stdout, "Cannot read the test continer logs:", err)
+	} else {
+		files["container.stdout.log"] = containerStdout
+		files["container.stderr.log"] = containerStderr
+		if !report.Passed() {
+			printTail("stdout", containerStdout)
+			printTail("stderr", containerStderr)
+		}
+	}
+
+	if err := saveArtifacts(files); err != nil {
+		fmt.Fprintln(stdout, "Cannot save the verification artifacts:", err)
+	}
+}
+
+// loadStandards reads the test spec. It returns nil when the default spec file does not exist,
+// a spec file the user named has to be there.
+func loadStandards() (*standards.Spec, error) {
+
+	if cfg.Standards == "" {
+		return nil, nil
+	}
+	if _, err := os.Stat(cfg.Standards); os.IsNotExist(err) && cfg.Standards == defaultStandards {
+		fmt.Fprintln(stdout, "No", defaultStandards, "found, skipping the company standards checks.")
+		return nil, nil
+	}
+	spec, err := standards.LoadSpec(cfg.Standards)
+	if err != nil {
+		return nil, errors.New("cannot read the standards spec " + cfg.Standards + ": " + err.Error())
+	}
+	return spec, nil
+}
+
+// defaultStandards is the spec file used when none is configured
+const defaultStandards = "standards.yaml"
+
+// candidate is the container under test as the standards checks see it
+type candidate struct {
+	containerInfo types.ContainerJSON
+}
+
+func (c *candidate) Config() (standards.ContainerConfig, error) {
+
+	info := c.containerInfo
+	if info.Config == nil {
+		return standards.ContainerConfig{}, errors.New("container " + info.ID + " has no config")
+	}
+	config := standards.ContainerConfig{
+		User:       info.Config.User,
+		Entrypoint: info.Config.Entrypoint,
+		Cmd:        info.Config.Cmd,
+		Env:        info.Config.Env,
+		Labels:     info.Config.Labels,
+	}
+	for port := range info.Config.ExposedPorts {
+		config.ExposedPorts = append(config.ExposedPorts, string(port))
+	}
+	return config, nil
+}
+
+// Run runs cmd inside the test container while it is running, and in a fresh container
+// from the same image when it has exited
+func (c *candidate) Run(cmd []string, options standards.RunOptions) (standards.CommandResult, error) {
+
+	ctx, cancel := withTimeout(cfg.CommandTimeout)
+	defer cancel()
+
+	var result *docker.CommandResult
+	var err error
+	if c.containerInfo.State != nil && c.containerInfo.State.Running {
+		result, err = dockerClient.Exec(ctx, c.containerInfo.ID, cmd, options.Env, options.User, options.Workdir)
+	} else {
+		result, err = dockerClient.RunCommand(ctx, c.containerInfo.Image, cmd, options.Env, options.User, options.Workdir,
+			time.Duration(cfg.CommandTimeout)*time.Second)
+	}
+	if err != nil {
+		return standards.CommandResult{}, err
+	}
+	if result.OOMKilled {
+		result.Stderr += "\n(killed: out of memory)"
+	}
+	return standards.CommandResult{Stdout: result.Stdout, Stderr: result.Stderr, ExitCode: result.StatusCode}, nil
+}
+
+func removeDockerContainer(container container.ContainerCreateCreatedBody) error {
+
+	var err error
+	fmt.Fprint(stdout, "Removing continer ", container.ID[0:11], "...")
+	err = removeContainer(container.ID)
+	if err != nil {
+		fmt.Fprintln(stdout, "failed.")
+		return err
+	}
+	fmt.Fprintln(stdout, "success.")
+	return nil
+}
+
+// Labels dockhand puts on every container it creates: the Jenkins label being tested,
+// who ran dockhand where, the run ID and when the run started
+const (
+	testLabel    = "dockhand.test-label"
+	ownerLabel   = "dockhand.owner"
+	runIDLabel   = "dockhand.run-id"
+	startedLabel = "dockhand.started"
+)
+
+// owner is the user@host running dockhand
+func owner() string {
+
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	host, _ := os.Hostname()
+	return name + "@" + host
+}
+
+// removeContainer removes a container, also after the run was interrupted
+func removeContainer(id string) error {
+
+	ctx, cancel := cleanupContext()
+	defer cancel()
+	return dockerClient.ContainerRemove(ctx, id)
+}
+
+// testContainerSpec describes the test container verify creates from the image
+func testContainerSpec() docker.ContainerSpec {
+
+	return docker.ContainerSpec{
+		Image:      cfg.ImageName,
+		Name:       testContainerName(),
+		Entrypoint: cfg.Container.Entrypoint,
+		Cmd:        cfg.Container.Cmd,
+		Env:        cfg.Container.Env,
+		User:       cfg.Container.User,
+		Workdir:    cfg.Container.Workdir,
+		Memory:     cfg.Container.Memory,
+		CPUs:       cfg.Container.CPUs,
+		Tmpfs:      cfg.Container.Tmpfs,
+		Mounts:     cfg.Container.Mounts,
+		Networks:   cfg.Container.Networks,
+		Ports:      agentPorts(),
+	}
+}
+
+// testContainerName is the name of the container verify creates, unique to the label and run
+func testContainerName() string {
+	return "DockhandTesting" + cfg.Label + "_" + runID
+}
+
+// jobName is the name of the Jenkins job created for the label
+func jobName() string {
+	return cfg.Label + "_JOB"
+}
+
+// jobConfig returns the Jenkins pipeline job definition for repoURL
+func jobConfig(repoURL string) string {
+
+	//TODO: Move this to a config file...
+	return `<?xml version='1.0' encoding='UTF-8'?>
+<flow-definition plugin="workflow-job@2.6">
+  <actions/>
+  <description></description>
+  <keepDependencies>false</keepDependencies>
+  <properties>
+    <com.coravy.hudson.plugins.github.GithubProjectProperty plugin="github@1.21.1">
+      <projectUrl>` + repoURL + `</projectUrl>
+      <displayName></displayName>
+    </com.coravy.hudson.plugins.github.GithubProjectProperty>
+    <org.jenkinsci.plugins.workflow.job.properties.PipelineTriggersJobProperty>
+      <triggers>
+        <hudson.triggers.TimerTrigger>
+          <spec>H */3 * * *</spec>
+        </hudson.triggers.TimerTrigger>
+        <com.cloudbees.jenkins.GitHubPushTrigger plugin="github@1.21.1">
+          <spec></spec>
+        </com.cloudbees.jenkins.GitHubPushTrigger>
+      </triggers>
+    </org.jenkinsci.plugins.workflow.job.properties.PipelineTriggersJobProperty>
+  </properties>
+  <definition class="org.jenkinsci.plugins.workflow.cps.CpsScmFlowDefinition" plugin="workflow-cps@2.17">
+    <scm class="hudson.plugins.git.GitSCM" plugin="git@3.0.0">
+      <configVersion>2</configVersion>
+      <userRemoteConfigs>
+        <hudson.plugins.git.UserRemoteConfig>
+          <url>` + repoURL + `</url>
+        </hudson.plugins.git.UserRemoteConfig>
+      </userRemoteConfigs>
+      <branches>
+        <hudson.plugins.git.BranchSpec>
+          <name>*/master</name>
+        </hudson.plugins.git.BranchSpec>
+      </branches>
+      <doGenerateSubmoduleConfigurations>false</doGenerateSubmoduleConfigurations>
+      <submoduleCfg class="list"/>
+      <extensions/>
+    </scm>
+    <scriptPath>Jenkinsfile</scriptPath>
+  </definition>
+  <triggers/>
+</flow-definition>`
+}
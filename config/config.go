@@ -0,0 +1,415 @@
+package config
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/stevebargelt/Dockhand/jenkins"
+	"github.com/stevebargelt/Dockhand/secrets"
+)
+
+// EnvPrefix is the prefix of the environment variables that override the config file
+const EnvPrefix = "DOCKHAND"
+
+// Redacted replaces secret values when the config is displayed
+const Redacted = "********"
+
+// Config is the effective Dockhand configuration. Values come from the config file,
+// then DOCKHAND_* environment variables, then command line flags, each overriding the last.
+type Config struct {
+	DockerHostURL    string `mapstructure:"dockerHostURL" yaml:"dockerHostURL"`
+	DockerTLSFolder  string `mapstructure:"dockerTLSFolder" yaml:"dockerTLSFolder"`
+	CertFile         string `mapstructure:"certFile" yaml:"certFile"`
+	KeyFile          string `mapstructure:"keyFile" yaml:"keyFile"`
+	CAFile           string `mapstructure:"caFile" yaml:"caFile"`
+	DockerTLSVerify  bool   `mapstructure:"dockerTLSVerify" yaml:"dockerTLSVerify"`
+	RegistryURL      string `mapstructure:"registryURL" yaml:"registryURL"`
+	RegistryUser     string `mapstructure:"registryUser" yaml:"registryUser"`
+	RegistryPassword string `mapstructure:"registryPassword" yaml:"registryPassword"`
+	ImageName        string `mapstructure:"imageName" yaml:"imageName"`
+	CloudName        string `mapstructure:"cloudName" yaml:"cloudName"`
+	Label            string `mapstructure:"label" yaml:"label"`
+	LabelMatch       string `mapstructure:"labelMatch" yaml:"labelMatch"`
+	JenkinsURL       string `mapstructure:"jenkinsURL" yaml:"jenkinsURL"`
+	JenkinsUser      string `mapstructure:"jenkinsUser" yaml:"jenkinsUser"`
+	JenkinsPassword  string `mapstructure:"jenkinsPassword" yaml:"jenkinsPassword"`
+	JenkinsScripts   string `mapstructure:"jenkinsScripts" yaml:"jenkinsScripts"`
+	RepoURL          string `mapstructure:"repoURL" yaml:"repoURL"`
+	Team             string `mapstructure:"team" yaml:"team"`
+	Standards        string `mapstructure:"standards" yaml:"standards"`
+	Artifacts        string `mapstructure:"artifacts" yaml:"artifacts"`
+
+	// JenkinsAPIToken is used with jenkinsUser instead of the password, JenkinsBearerToken
+	// instead of both. The cert, key and CA files are for a Jenkins that asks for a client certificate.
+	JenkinsAPIToken    string `mapstructure:"jenkinsAPIToken" yaml:"jenkinsAPIToken"`
+	JenkinsBearerToken string `mapstructure:"jenkinsBearerToken" yaml:"jenkinsBearerToken"`
+	JenkinsCertFile    string `mapstructure:"jenkinsCertFile" yaml:"jenkinsCertFile"`
+	JenkinsKeyFile     string `mapstructure:"jenkinsKeyFile" yaml:"jenkinsKeyFile"`
+	JenkinsCAFile      string `mapstructure:"jenkinsCAFile" yaml:"jenkinsCAFile"`
+
+	// StartupWait is how many seconds verify waits to see if the test container exits,
+	// CommandTimeout how long a standards check command may run
+	StartupWait    int `mapstructure:"startupWait" yaml:"startupWait"`
+	CommandTimeout int `mapstructure:"commandTimeout" yaml:"commandTimeout"`
+
+	Build     BuildConfig     `mapstructure:"build" yaml:"build"`
+	Container ContainerConfig `mapstructure:"container" yaml:"container"`
+	Agent     AgentConfig     `mapstructure:"agent" yaml:"agent"`
+	Template  TemplateConfig  `mapstructure:"template" yaml:"template"`
+	Timeouts  TimeoutsConfig  `mapstructure:"timeouts" yaml:"timeouts"`
+	Vault     VaultConfig     `mapstructure:"vault" yaml:"vault"`
+}
+
+// BuildConfig says what the image is built from and how. Context is a local directory, a tar file
+// ("-" reads the tar from stdin) or a Git URL; repoURL is built when it is empty.
+// Args and Labels are KEY=VALUE lists since viper would lower case the keys of a map.
+// GitCommit is recorded in the image labels, it is looked up with git for a local directory context.
+type BuildConfig struct {
+	Context    string   `mapstructure:"context" yaml:"context"`
+	Ref        string   `mapstructure:"ref" yaml:"ref"`
+	Subdir     string   `mapstructure:"subdir" yaml:"subdir"`
+	Dockerfile string   `mapstructure:"dockerfile" yaml:"dockerfile"`
+	Args       []string `mapstructure:"args" yaml:"args"`
+	Target     string   `mapstructure:"target" yaml:"target"`
+	Labels     []string `mapstructure:"labels" yaml:"labels"`
+	CacheFrom  []string `mapstructure:"cacheFrom" yaml:"cacheFrom"`
+	NoCache    bool     `mapstructure:"noCache" yaml:"noCache"`
+	Pull       bool     `mapstructure:"pull" yaml:"pull"`
+	GitCommit  string   `mapstructure:"gitCommit" yaml:"gitCommit"`
+}
+
+// ContainerConfig says how verify runs the test container, so it can be run the way Jenkins
+// will run the slave. Env is a KEY=VALUE list, Memory a size such as 512m, Tmpfs entries are
+// path[:options] and Mounts source:target[:options]. Empty fields keep the image's settings.
+type ContainerConfig struct {
+	Entrypoint []string `mapstructure:"entrypoint" yaml:"entrypoint"`
+	Cmd        []string `mapstructure:"cmd" yaml:"cmd"`
+	Env        []string `mapstructure:"env" yaml:"env"`
+	User       string   `mapstructure:"user" yaml:"user"`
+	Workdir    string   `mapstructure:"workdir" yaml:"workdir"`
+	Memory     string   `mapstructure:"memory" yaml:"memory"`
+	CPUs       float64  `mapstructure:"cpus" yaml:"cpus"`
+	Tmpfs      []string `mapstructure:"tmpfs" yaml:"tmpfs"`
+	Mounts     []string `mapstructure:"mounts" yaml:"mounts"`
+	Networks   []string `mapstructure:"networks" yaml:"networks"`
+}
+
+// AgentConfig says how verify checks the image can act as a Jenkins docker slave.
+// Type is "ssh", "jnlp" or empty for either. Handshake starts a real agent connection:
+// for ssh slaves dockhand connects to sshd through port 22 of the test container, published on
+// the Docker host, for jnlp slaves the image
+// connects to a stub master dockhand runs, reached on StubHost (the Docker bridge gateway when empty).
+type AgentConfig struct {
+	Verify           bool   `mapstructure:"verify" yaml:"verify"`
+	Type             string `mapstructure:"type" yaml:"type"`
+	MinJavaVersion   int    `mapstructure:"minJavaVersion" yaml:"minJavaVersion"`
+	MaxJavaVersion   int    `mapstructure:"maxJavaVersion" yaml:"maxJavaVersion"`
+	User             string `mapstructure:"user" yaml:"user"`
+	Home             string `mapstructure:"home" yaml:"home"`
+	Handshake        bool   `mapstructure:"handshake" yaml:"handshake"`
+	StubHost         string `mapstructure:"stubHost" yaml:"stubHost"`
+	HandshakeTimeout int    `mapstructure:"handshakeTimeout" yaml:"handshakeTimeout"`
+}
+
+// TemplateConfig is the docker slave template register creates in Jenkins for the label, see
+// jenkins.DockerTemplate. Launch is attach, ssh or jnlp, PullStrategy always, latest or never.
+// Memory limits are in MB, IdleMinutes 0 and the empty fields keep the docker plugin's defaults.
+type TemplateConfig struct {
+	RemoteFS              string   `mapstructure:"remoteFS" yaml:"remoteFS"`
+	InstanceCap           int      `mapstructure:"instanceCap" yaml:"instanceCap"`
+	Launch                string   `mapstructure:"launch" yaml:"launch"`
+	SSHCredentialsID      string   `mapstructure:"sshCredentialsID" yaml:"sshCredentialsID"`
+	PullStrategy          string   `mapstructure:"pullStrategy" yaml:"pullStrategy"`
+	MemoryLimit           int      `mapstructure:"memoryLimit" yaml:"memoryLimit"`
+	MemorySwap            int      `mapstructure:"memorySwap" yaml:"memorySwap"`
+	CPUShares             int      `mapstructure:"cpuShares" yaml:"cpuShares"`
+	Volumes               []string `mapstructure:"volumes" yaml:"volumes"`
+	Env                   []string `mapstructure:"env" yaml:"env"`
+	ExtraHosts            []string `mapstructure:"extraHosts" yaml:"extraHosts"`
+	RegistryCredentialsID string   `mapstructure:"registryCredentialsID" yaml:"registryCredentialsID"`
+	IdleMinutes           int      `mapstructure:"idleMinutes" yaml:"idleMinutes"`
+	Network               string   `mapstructure:"network" yaml:"network"`
+}
+
+// DockerTemplate returns the template for label running image
+func (t TemplateConfig) DockerTemplate(label string, image string) jenkins.DockerTemplate {
+
+	return jenkins.DockerTemplate{
+		Label:                 label,
+		Image:                 image,
+		RemoteFS:              t.RemoteFS,
+		InstanceCap:           t.InstanceCap,
+		Launch:                t.Launch,
+		SSHCredentialsID:      t.SSHCredentialsID,
+		PullStrategy:          t.PullStrategy,
+		MemoryLimit:           t.MemoryLimit,
+		MemorySwap:            t.MemorySwap,
+		CPUShares:             t.CPUShares,
+		Volumes:               t.Volumes,
+		Env:                   t.Env,
+		ExtraHosts:            t.ExtraHosts,
+		RegistryCredentialsID: t.RegistryCredentialsID,
+		IdleMinutes:           t.IdleMinutes,
+		Network:               t.Network,
+	}
+}
+
+// TimeoutsConfig limits how many seconds each kind of Docker operation may take, 0 means no limit.
+// Docker is for the quick calls: creating, starting, inspecting and listing containers and images.
+type TimeoutsConfig struct {
+	Build  int `mapstructure:"build" yaml:"build"`
+	Push   int `mapstructure:"push" yaml:"push"`
+	Pull   int `mapstructure:"pull" yaml:"pull"`
+	Docker int `mapstructure:"docker" yaml:"docker"`
+}
+
+// VaultConfig locates the Vault server used by secret://vault/ references
+type VaultConfig struct {
+	Address string `mapstructure:"address" yaml:"address"`
+	Token   string `mapstructure:"token" yaml:"token"`
+}
+
+// defaults registers every key without a flag with viper so DOCKHAND_* variables can
+// override it even when the config file does not mention it
+var defaults = map[string]interface{}{
+	"registryPassword":       "",
+	"jenkinsPassword":        "",
+	"team":                   "",
+	"labelMatch":             "exact",
+	"jenkinsScripts":         "console",
+	"jenkinsAPIToken":        "",
+	"jenkinsBearerToken":     "",
+	"jenkinsCertFile":        "",
+	"jenkinsKeyFile":         "",
+	"jenkinsCAFile":          "",
+	"startupWait":            10,
+	"commandTimeout":         300,
+	"build.ref":              "",
+	"build.subdir":           "",
+	"build.gitCommit":        "",
+	"container.user":         "",
+	"container.workdir":      "",
+	"container.memory":       "",
+	"container.cpus":         0.0,
+	"agent.verify":           true,
+	"agent.type":             "",
+	"agent.minJavaVersion":   8,
+	"agent.maxJavaVersion":   0,
+	"agent.user":             "jenkins",
+	"agent.home":             "/home/jenkins",
+	"agent.stubHost":         "",
+	"agent.handshakeTimeout": 60,
+	"timeouts.build":         3600,
+	"timeouts.push":          1800,
+	"timeouts.pull":          1800,
+	"timeouts.docker":        60,
+	"vault.address":          os.Getenv("VAULT_ADDR"),
+	"vault.token":            "secret://env/VAULT_TOKEN",
+
+	"template.remoteFS":              "/home/jenkins",
+	"template.instanceCap":           0,
+	"template.launch":                "attach",
+	"template.sshCredentialsID":      "",
+	"template.pullStrategy":          "latest",
+	"template.memoryLimit":           0,
+	"template.memorySwap":            0,
+	"template.cpuShares":             0,
+	"template.registryCredentialsID": "",
+	"template.idleMinutes":           0,
+	"template.network":               "",
+}
+
+// Load reads the config file (if there is one) into v and returns the merged config.
+// A missing file is only an error when required is set, i.e. the user asked for it explicitly.
+func Load(v *viper.Viper, file string, required bool) (*Config, error) {
+
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+
+	if file != "" {
+		_, err := os.Stat(file)
+		switch {
+		case err == nil:
+			v.SetConfigFile(file)
+			if err := v.ReadInConfig(); err != nil {
+				return nil, err
+			}
+		case !os.IsNotExist(err) || required:
+			return nil, err
+		}
+	}
+
+	var c Config
+	if err := v.Unmarshal(&c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Validate checks that the settings every command relies on are present and well formed
+func (c *Config) Validate() error {
+
+	var problems []string
+
+	required := []struct{ key, value string }{
+		{"imageName", c.ImageName},
+		{"label", c.Label},
+	}
+	for _, r := range required {
+		if strings.TrimSpace(r.value) == "" {
+			problems = append(problems, r.key+" is required")
+		}
+	}
+
+	urls := []struct {
+		key, value string
+		schemes    []string
+	}{
+		{"dockerHostURL", c.DockerHostURL, []string{"tcp", "unix", "http", "https"}},
+		{"registryURL", c.RegistryURL, []string{"http", "https"}},
+		{"jenkinsURL", c.JenkinsURL, []string{"http", "https"}},
+		{"repoURL", c.RepoURL, []string{"http", "https", "git", "ssh"}},
+	}
+	for _, u := range urls {
+		if u.value == "" {
+			continue
+		}
+		if u.key == "repoURL" && scpGitURL.MatchString(u.value) {
+			continue
+		}
+		if err := checkURL(u.value, u.schemes); err != nil {
+			problems = append(problems, u.key+" "+err.Error())
+		}
+	}
+
+	// the names sent to Jenkins; the ones left empty are either reported as required above
+	// or refused when a command needs them
+	if err, ok := jenkins.ValidateTemplate(c.CloudName, c.Label, c.ImageName).(*jenkins.ValidationError); ok {
+		for _, field := range err.Fields {
+			if field.Value != "" {
+				problems = append(problems, field.Error())
+			}
+		}
+	}
+	// the label and image are checked above
+	if err, ok := c.Template.DockerTemplate("label", "image").Validate().(*jenkins.ValidationError); ok {
+		for _, field := range err.Fields {
+			problems = append(problems, "template."+field.Error())
+		}
+	}
+	if c.LabelMatch != "" && c.LabelMatch != "exact" && c.LabelMatch != "ignoreCase" {
+		problems = append(problems, "labelMatch must be exact or ignoreCase")
+	}
+	if c.JenkinsScripts != "" && c.JenkinsScripts != "console" && c.JenkinsScripts != "scriptler" {
+		problems = append(problems, "jenkinsScripts must be console or scriptler")
+	}
+	if (c.JenkinsCertFile == "") != (c.JenkinsKeyFile == "") {
+		problems = append(problems, "jenkinsCertFile and jenkinsKeyFile must be set together")
+	}
+	if c.Agent.Type != "" && c.Agent.Type != "ssh" && c.Agent.Type != "jnlp" {
+		problems = append(problems, "agent.type must be ssh, jnlp or empty")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid config: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Redacted returns a copy of the config that is safe to print.
+// secret:// references are kept since they only say where a secret lives.
+func (c Config) Redacted() Config {
+
+	for _, secret := range []*string{&c.RegistryPassword, &c.JenkinsPassword, &c.JenkinsAPIToken, &c.JenkinsBearerToken, &c.Vault.Token} {
+		if *secret != "" && !secrets.IsReference(*secret) {
+			*secret = Redacted
+		}
+	}
+	return c
+}
+
+// ResolveRegistrySecrets replaces secret:// references in the registry credentials with their values.
+// Only the commands that talk to the registry call it, so the others run without those secrets.
+func (c *Config) ResolveRegistrySecrets(r *secrets.Resolver) error {
+	return c.resolveSecrets(r, &c.RegistryUser, &c.RegistryPassword)
+}
+
+// ResolveJenkinsSecrets replaces secret:// references in the Jenkins credentials with their values
+func (c *Config) ResolveJenkinsSecrets(r *secrets.Resolver) error {
+	return c.resolveSecrets(r, &c.JenkinsUser, &c.JenkinsPassword, &c.JenkinsAPIToken, &c.JenkinsBearerToken)
+}
+
+// resolveSecrets resolves the references among values in place. The vault token is only resolved,
+// and a vault provider registered with r, when one of them is a secret://vault/ reference.
+func (c *Config) resolveSecrets(r *secrets.Resolver, values ...*string) error {
+
+	for _, value := range values {
+		if strings.HasPrefix(*value, secrets.Scheme+"vault/") {
+			if err := c.registerVault(r); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	for _, value := range values {
+		resolved, err := r.Resolve(*value)
+		if err != nil {
+			return err
+		}
+		*value = resolved
+	}
+	return nil
+}
+
+// registerVault registers a vault provider with r, resolving the vault token
+func (c *Config) registerVault(r *secrets.Resolver) error {
+
+	if c.Vault.Address == "" {
+		return errors.New("secret://vault/ references need vault.address or VAULT_ADDR to be set")
+	}
+	token, err := r.Resolve(c.Vault.Token)
+	if err != nil {
+		return errors.New("cannot get the vault token: " + err.Error())
+	}
+	r.Register("vault", secrets.NewVault(c.Vault.Address, token))
+	return nil
+}
+
+// scpGitURL matches the scp-like user@host:path form of Git remotes, e.g. git@github.com:org/repo.git
+var scpGitURL = regexp.MustCompile(`^[\w.-]+@[\w.-]+:.+$`)
+
+// IsGitURL reports whether s is a Git remote rather than a local build context: an http(s), git or
+// ssh URL, an scp-like user@host:path remote or anything ending in .git, with an optional #ref:subdir
+func IsGitURL(s string) bool {
+
+	for _, prefix := range []string{"http://", "https://", "git://", "ssh://"} {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	remote := strings.SplitN(s, "#", 2)[0]
+	return scpGitURL.MatchString(remote) || strings.HasSuffix(remote, ".git")
+}
+
+func checkURL(value string, schemes []string) error {
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return errors.New("is not a valid URL: " + err.Error())
+	}
+	for _, s := range schemes {
+		if u.Scheme == s {
+			return nil
+		}
+	}
+	return errors.New("must use one of the schemes " + strings.Join(schemes, ", ") + ": " + value)
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stevebargelt/Dockhand/secrets"
+)
+
+func writeConfig(t *testing.T, contents string) string {
+
+	dir, err := ioutil.TempDir("", "dockhand-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "dockhand.yaml")
+	if err := ioutil.WriteFile(file, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoadPrecedence(t *testing.T) {
+
+	file := writeConfig(t, "label: FromFile\nimageName: file/image\ncloudName: FileCloud\njenkinsUser: fileuser\n")
+	defer os.RemoveAll(filepath.Dir(file))
+
+	os.Setenv("DOCKHAND_IMAGENAME", "env/image")
+	os.Setenv("DOCKHAND_CLOUDNAME", "EnvCloud")
+	defer os.Unsetenv("DOCKHAND_IMAGENAME")
+	defer os.Unsetenv("DOCKHAND_CLOUDNAME")
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.String("cloudname", "DefaultCloud", "")
+	fs.String("jenkinsurl", "http://default.example.com", "")
+	if err := fs.Parse([]string{"--cloudname", "FlagCloud"}); err != nil {
+		t.Fatal(err)
+	}
+
+	v := viper.New()
+	v.BindPFlag("cloudName", fs.Lookup("cloudname"))
+	v.BindPFlag("jenkinsURL", fs.Lookup("jenkinsurl"))
+
+	c, err := Load(v, file, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, got, want string
+	}{
+		{"file only", c.Label, "FromFile"},
+		{"env over file", c.ImageName, "env/image"},
+		{"flag over env", c.CloudName, "FlagCloud"},
+		{"flag default", c.JenkinsURL, "http://default.example.com"},
+		{"file over flag default", c.JenkinsUser, "fileuser"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+
+	if _, err := Load(viper.New(), "does-not-exist.yaml", false); err != nil {
+		t.Errorf("optional missing file: unexpected error %v", err)
+	}
+	if _, err := Load(viper.New(), "does-not-exist.yaml", true); err == nil {
+		t.Error("required missing file: expected an error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	valid := Config{
+		DockerHostURL: "tcp://docker.example.com:2376",
+		ImageName:     "example/image",
+		Label:         "Team_DotNet",
+		JenkinsURL:    "https://jenkins.example.com",
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("valid config: unexpected error %v", err)
+	}
+	for _, repo := range []string{"https://github.com/example/repo.git", "ssh://git@github.com/example/repo.git", "git@github.com:example/repo.git"} {
+		c := valid
+		c.RepoURL = repo
+		if err := c.Validate(); err != nil {
+			t.Errorf("repoURL %s: unexpected error %v", repo, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(*Config)
+	}{
+		{"missing label", func(c *Config) { c.Label = "" }},
+		{"label with spaces", func(c *Config) { c.Label = "Team DotNet" }},
+		{"label expression", func(c *Config) { c.Label = "TeamA&&linux" }},
+		{"image with upper case", func(c *Config) { c.ImageName = "Example/Image" }},
+		{"cloud name with slash", func(c *Config) { c.CloudName = "docker/azure" }},
+		{"bad jenkins scheme", func(c *Config) { c.JenkinsURL = "ftp://jenkins.example.com" }},
+		{"bad docker scheme", func(c *Config) { c.DockerHostURL = "docker.example.com:2376" }},
+		{"bad repo scheme", func(c *Config) { c.RepoURL = "ftp://example.com/repo.git" }},
+		{"jenkins cert without key", func(c *Config) { c.JenkinsCertFile = "/certs/jenkins.pem" }},
+		{"ssh launch without credentials", func(c *Config) { c.Template.Launch = "ssh" }},
+		{"unknown pull strategy", func(c *Config) { c.Template.PullStrategy = "sometimes" }},
+	}
+	for _, tt := range tests {
+		c := valid
+		tt.mutate(&c)
+		if err := c.Validate(); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestRedacted(t *testing.T) {
+
+	c := Config{RegistryUser: "admin", RegistryPassword: "hunter2", JenkinsAPIToken: "11abcdef"}
+	r := c.Redacted()
+	if r.RegistryPassword != Redacted || r.RegistryUser != "admin" || r.JenkinsAPIToken != Redacted {
+		t.Errorf("unexpected redaction %+v", r)
+	}
+	if r.JenkinsPassword != "" {
+		t.Error("empty secrets should stay empty")
+	}
+	if c.RegistryPassword != "hunter2" {
+		t.Error("Redacted must not modify the original config")
+	}
+
+	ref := Config{JenkinsPassword: "secret://env/JENKINS_PASSWORD"}
+	if r := ref.Redacted(); r.JenkinsPassword != ref.JenkinsPassword {
+		t.Errorf("references should be shown as is, got %q", r.JenkinsPassword)
+	}
+}
+
+func TestResolveSecrets(t *testing.T) {
+
+	os.Setenv("DOCKHAND_TEST_JENKINS_PASSWORD", "correcthorse")
+	defer os.Unsetenv("DOCKHAND_TEST_JENKINS_PASSWORD")
+
+	c := Config{JenkinsUser: "steve", JenkinsPassword: "secret://env/DOCKHAND_TEST_JENKINS_PASSWORD", RegistryPassword: "secret://env/DOCKHAND_TEST_UNSET"}
+	if err := c.ResolveJenkinsSecrets(secrets.NewResolver()); err != nil {
+		t.Fatal(err)
+	}
+	if c.JenkinsUser != "steve" || c.JenkinsPassword != "correcthorse" {
+		t.Errorf("unexpected credentials %q/%q", c.JenkinsUser, c.JenkinsPassword)
+	}
+	if c.RegistryPassword != "secret://env/DOCKHAND_TEST_UNSET" {
+		t.Errorf("registry password resolved with the Jenkins ones: %q", c.RegistryPassword)
+	}
+
+	if err := c.ResolveRegistrySecrets(secrets.NewResolver()); err == nil {
+		t.Error("expected an error for an unset variable")
+	}
+}
+
+func TestResolveSecretsVault(t *testing.T) {
+
+	// a vault address without a token only matters to secret://vault/ references
+	c := Config{
+		RegistryPassword: "plain",
+		Vault:            VaultConfig{Address: "https://vault.example.com", Token: "secret://env/DOCKHAND_TEST_UNSET"},
+	}
+	if err := c.ResolveRegistrySecrets(secrets.NewResolver()); err != nil {
+		t.Errorf("no vault reference: unexpected error %v", err)
+	}
+
+	c.JenkinsPassword = "secret://vault/ci/jenkins#password"
+	err := c.ResolveJenkinsSecrets(secrets.NewResolver())
+	if err == nil || !strings.Contains(err.Error(), "vault token") {
+		t.Errorf("vault reference without a token: got %v", err)
+	}
+
+	c.Vault.Address = ""
+	if err := c.ResolveJenkinsSecrets(secrets.NewResolver()); err == nil || !strings.Contains(err.Error(), "vault.address") {
+		t.Errorf("vault reference without an address: got %v", err)
+	}
+}
+
+func TestIsGitURL(t *testing.T) {
+
+	tests := []struct {
+		context string
+		git     bool
+	}{
+		{"https://github.com/example/repo.git", true},
+		{"git://example.com/repo", true},
+		{"ssh://git@example.com/repo", true},
+		{"git@github.com:example/repo.git", true},
+		{"deploy@git.example.com:/srv/repo#main:docker", true},
+		{"build/repo.git", true},
+		{".", false},
+		{"build/context", false},
+		{"context.tar.gz", false},
+		{"-", false},
+	}
+	for _, test := range tests {
+		if got := IsGitURL(test.context); got != test.git {
+			t.Errorf("IsGitURL(%q) = %v, want %v", test.context, got, test.git)
+		}
+	}
+}
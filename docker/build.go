@@ -0,0 +1,69 @@
+package docker
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// BuildError is returned when a build fails on the Docker host, e.g. a RUN instruction exits non-zero
+type BuildError struct {
+	// Step is the "Step n/m : INSTRUCTION" line of the step that failed, if the build got that far
+	Step    string
+	Message string
+	Code    int
+}
+
+func (e *BuildError) Error() string {
+
+	if e.Step == "" {
+		return "build failed: " + e.Message
+	}
+	return "build failed at " + e.Step + ": " + e.Message
+}
+
+// readBuildOutput consumes the build output stream, passing every message on to progress,
+// and returns the ID of the image built or a *BuildError
+func readBuildOutput(r io.Reader, progress ProgressFunc) (string, error) {
+
+	var step, imageID string
+	var buildErr *BuildError
+
+	err := decodeStream(r, func(m JSONMessage) error {
+		if progress != nil {
+			progress(m)
+		}
+
+		if msg := m.ErrorMessage(); msg != "" {
+			buildErr = &BuildError{Step: step, Message: strings.TrimSpace(msg)}
+			if m.ErrorDetail != nil {
+				buildErr.Code = m.ErrorDetail.Code
+			}
+			return nil
+		}
+
+		line := strings.TrimSpace(m.Stream)
+		switch {
+		case strings.HasPrefix(line, "Step "):
+			step = line
+		case strings.HasPrefix(line, "Successfully built ") && imageID == "":
+			// the aux message, where sent, carries the full ID
+			imageID = strings.TrimPrefix(line, "Successfully built ")
+		}
+
+		if m.Aux != nil {
+			var aux struct{ ID string }
+			if json.Unmarshal(*m.Aux, &aux) == nil && aux.ID != "" {
+				imageID = aux.ID
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if buildErr != nil {
+		return "", buildErr
+	}
+	return imageID, nil
+}
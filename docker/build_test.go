@@ -0,0 +1,100 @@
+package docker
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReadBuildOutput(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		stream  string
+		wantID  string
+		wantErr string
+		step    string
+	}{
+		{
+			name: "success with aux ID",
+			stream: `{"stream":"Step 1/2 : FROM alpine\n"}
+{"stream":" ---> 4e38e38c8ce0\n"}
+{"stream":"Step 2/2 : RUN true\n"}
+{"aux":{"ID":"sha256:0123456789abcdef"}}
+{"stream":"Successfully built 0123456789ab\n"}`,
+			wantID: "sha256:0123456789abcdef",
+		},
+		{
+			name: "success with short ID only",
+			stream: `{"stream":"Step 1/1 : FROM alpine\n"}
+{"stream":"Successfully built 0123456789ab\n"}`,
+			wantID: "0123456789ab",
+		},
+		{
+			name: "failing step",
+			stream: `{"stream":"Step 1/3 : FROM alpine\n"}
+{"stream":"Step 2/3 : RUN exit 3\n"}
+{"stream":" ---> Running in 5ab0c7b2b1c4\n"}
+{"errorDetail":{"code":3,"message":"The command '/bin/sh -c exit 3' returned a non-zero code: 3"},"error":"The command '/bin/sh -c exit 3' returned a non-zero code: 3"}`,
+			wantErr: "returned a non-zero code: 3",
+			step:    "Step 2/3 : RUN exit 3",
+		},
+		{
+			name:    "error before any step",
+			stream:  `{"error":"unable to prepare context: unable to 'git clone'"}`,
+			wantErr: "unable to prepare context",
+		},
+		{
+			name:    "garbage",
+			stream:  `{"stream":"Step 1/1`,
+			wantErr: "unexpected EOF",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var messages int
+			id, err := readBuildOutput(strings.NewReader(tt.stream), func(JSONMessage) { messages++ })
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				if id != tt.wantID {
+					t.Errorf("got ID %q, want %q", id, tt.wantID)
+				}
+				if messages != strings.Count(tt.stream, "\n")+1 {
+					t.Errorf("progress saw %d messages", messages)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+			if tt.step != "" {
+				buildErr, ok := err.(*BuildError)
+				if !ok {
+					t.Fatalf("got %T, want *BuildError", err)
+				}
+				if buildErr.Step != tt.step || buildErr.Code != 3 {
+					t.Errorf("got step %q code %d", buildErr.Step, buildErr.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestTerminalProgress(t *testing.T) {
+
+	var out bytes.Buffer
+	progress := TerminalProgress(&out)
+	progress(JSONMessage{Stream: "Step 1/1 : FROM alpine\n"})
+	progress(JSONMessage{ID: "4e38e38c8ce0", Status: "Downloading", Progress: "[=>   ] 1MB/5MB"})
+	progress(JSONMessage{ID: "4e38e38c8ce0", Status: "Downloading", Progress: "[===> ] 4MB/5MB"})
+	progress(JSONMessage{ID: "4e38e38c8ce0", Status: "Pull complete"})
+	progress(JSONMessage{Error: "boom"})
+
+	want := "Step 1/1 : FROM alpine\n4e38e38c8ce0: Downloading\n4e38e38c8ce0: Pull complete\nERROR: boom\n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
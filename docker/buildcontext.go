@@ -0,0 +1,263 @@
+package docker
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/builder/dockerignore"
+	"github.com/docker/docker/pkg/fileutils"
+)
+
+// BuildOptions describes where the files for a build come from and how to build them.
+// Exactly one of Dir, Tar and Remote must be set.
+type BuildOptions struct {
+	// Dir is a local directory sent to the Docker host as the build context, honoring its .dockerignore
+	Dir string
+	// Tar is a ready made build context tar stream, optionally gzip compressed
+	Tar io.Reader
+	// Remote is a Git URL the Docker host clones itself
+	Remote string
+	// Ref and Subdir pick the branch, tag or commit and the directory in the Remote repo (repo#ref:subdir)
+	Ref    string
+	Subdir string
+	// Dockerfile is the path of the Dockerfile within the context, "Dockerfile" when empty
+	Dockerfile string
+
+	// BuildArgs are the values of the Dockerfile ARG instructions
+	BuildArgs map[string]string
+	// Target is the stage of a multi-stage Dockerfile to build
+	Target string
+	// Labels are added to the image, e.g. to trace it back to its source
+	Labels map[string]string
+	// CacheFrom are images the build may use as cache sources
+	CacheFrom []string
+	// NoCache builds every step from scratch
+	NoCache bool
+	// Pull always pulls newer versions of the base images
+	Pull bool
+}
+
+// RemoteContext returns Remote with Ref and Subdir added the way the Docker host expects them
+func (o BuildOptions) RemoteContext() string {
+
+	if o.Remote == "" || strings.Contains(o.Remote, "#") || (o.Ref == "" && o.Subdir == "") {
+		return o.Remote
+	}
+	fragment := o.Ref
+	if o.Subdir != "" {
+		fragment += ":" + o.Subdir
+	}
+	return o.Remote + "#" + fragment
+}
+
+// imageBuildOptions returns the options for the Docker API build call
+func (o BuildOptions) imageBuildOptions(tags []string) types.ImageBuildOptions {
+
+	var buildArgs map[string]*string
+	if len(o.BuildArgs) > 0 {
+		buildArgs = make(map[string]*string, len(o.BuildArgs))
+		for name, value := range o.BuildArgs {
+			value := value
+			buildArgs[name] = &value
+		}
+	}
+
+	return types.ImageBuildOptions{
+		RemoteContext: o.RemoteContext(),
+		Dockerfile:    o.Dockerfile,
+		Tags:          tags,
+		BuildArgs:     buildArgs,
+		Labels:        o.Labels,
+		CacheFrom:     o.CacheFrom,
+		NoCache:       o.NoCache,
+		PullParent:    o.Pull,
+		Remove:        true,
+	}
+}
+
+// describe returns a short human readable description of the build context
+func (o BuildOptions) describe() string {
+
+	switch {
+	case o.Dir != "":
+		return "directory " + o.Dir
+	case o.Tar != nil:
+		return "tar stream"
+	}
+	return "repo " + o.RemoteContext()
+}
+
+func (o BuildOptions) validate() error {
+
+	set := 0
+	for _, isSet := range []bool{o.Dir != "", o.Tar != nil, o.Remote != ""} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("exactly one of a directory, a tar stream or a remote repo must be given as the build context")
+	}
+	if (o.Ref != "" || o.Subdir != "") && o.Remote == "" {
+		return errors.New("a ref or subdirectory can only be given with a remote repo build context")
+	}
+	return nil
+}
+
+// contextReader returns the tar stream to send to the Docker host, or nil for a remote context
+func (o BuildOptions) contextReader() (io.ReadCloser, error) {
+
+	switch {
+	case o.Tar != nil:
+		return ioutil.NopCloser(o.Tar), nil
+	case o.Dir != "":
+		dockerfile := o.Dockerfile
+		if dockerfile == "" {
+			dockerfile = "Dockerfile"
+		}
+		return tarDirectory(o.Dir, dockerfile)
+	}
+	return nil, nil
+}
+
+// tarDirectory streams the contents of dir as a tar archive, leaving out what its .dockerignore excludes.
+// The Dockerfile and .dockerignore are always sent since the Docker host needs them.
+func tarDirectory(dir, dockerfile string) (io.ReadCloser, error) {
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, errors.New("build context " + dir + " is not a directory")
+	}
+
+	var excludes []string
+	if f, err := os.Open(filepath.Join(dir, ".dockerignore")); err == nil {
+		excludes, err = dockerignore.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	patterns, patternDirs, exceptions, err := fileutils.CleanPatterns(excludes)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := map[string]bool{
+		filepath.Clean(filepath.FromSlash(dockerfile)): true,
+		".dockerignore": true,
+	}
+
+	// keepsBelow reports whether an excluded directory holds something that is still sent, in which
+	// case it is walked instead of skipped: the Dockerfile or a path an exception (!) pattern could match
+	keepsBelow := func(rel string) bool {
+		for name := range keep {
+			if strings.HasPrefix(name, rel+string(filepath.Separator)) {
+				return true
+			}
+		}
+		if !exceptions {
+			return false
+		}
+		relParts := strings.Split(rel, string(filepath.Separator))
+		for i, pattern := range patterns {
+			if strings.HasPrefix(pattern, "!") && couldMatchBelow(patternDirs[i], relParts) {
+				return true
+			}
+		}
+		return false
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(writer)
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil || rel == "." {
+				return err
+			}
+			name := filepath.ToSlash(rel)
+
+			if !keep[rel] {
+				excluded, err := fileutils.OptimizedMatches(rel, patterns, patternDirs)
+				if err != nil {
+					return err
+				}
+				if excluded {
+					if info.IsDir() && !keepsBelow(rel) {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+			}
+			return addToTar(tw, path, name, info)
+		})
+		if err == nil {
+			err = tw.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+	return reader, nil
+}
+
+// couldMatchBelow reports whether a pattern split at its separators could match a path inside
+// the directory split into dirParts
+func couldMatchBelow(patternParts, dirParts []string) bool {
+
+	for i, part := range patternParts {
+		if strings.Contains(part, "**") || i == len(dirParts) {
+			return true
+		}
+		if matched, _ := filepath.Match(part, dirParts[i]); !matched {
+			return false
+		}
+	}
+	return false
+}
+
+func addToTar(tw *tar.Writer, path, name string, info os.FileInfo) error {
+
+	link := ""
+	if info.Mode()&os.ModeSymlink != 0 {
+		var err error
+		if link, err = os.Readlink(path); err != nil {
+			return err
+		}
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,159 @@
+package docker
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeContext creates a build context directory holding files, keyed by their slash separated path
+func writeContext(t *testing.T, files map[string]string) string {
+
+	dir, err := ioutil.TempDir("", "dockhand-context")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, contents := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// tarNames returns the sorted names in the tar archive of dir
+func tarNames(t *testing.T, dir, dockerfile string) []string {
+
+	r, err := tarDirectory(dir, dockerfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var names []string
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestTarDirectory(t *testing.T) {
+
+	dir := writeContext(t, map[string]string{
+		".dockerignore":        ".git\n**/*.log\nbuild/Dockerfile.dev\n.dockerignore\n",
+		"build/Dockerfile.dev": "FROM alpine\n",
+		"app/main.go":          "package main\n",
+		"app/debug.log":        "noise\n",
+		".git/HEAD":            "ref: refs/heads/master\n",
+	})
+	defer os.RemoveAll(dir)
+
+	got := tarNames(t, dir, "build/Dockerfile.dev")
+	want := []string{".dockerignore", "app/", "app/main.go", "build/", "build/Dockerfile.dev"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestTarDirectoryExcludedDirectories(t *testing.T) {
+
+	files := map[string]string{
+		"build/Dockerfile":   "FROM alpine\n",
+		"build/out.bin":      "binary\n",
+		"vendor/lib/LICENSE": "MIT\n",
+		"vendor/lib/lib.go":  "package lib\n",
+		"docs/a/keep.md":     "# keep\n",
+		"docs/a/drop.md":     "# drop\n",
+		"tmp/cache":          "noise\n",
+	}
+
+	// without exceptions only the directory with the Dockerfile is walked
+	files[".dockerignore"] = "build\nvendor\ndocs\ntmp\n"
+	dir := writeContext(t, files)
+	defer os.RemoveAll(dir)
+	got := tarNames(t, dir, "build/Dockerfile")
+	want := []string{".dockerignore", "build/Dockerfile"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("without exceptions: got %v, want %v", got, want)
+	}
+
+	files[".dockerignore"] = "build\nvendor\n!vendor/*/LICENSE\ndocs\n!**/keep.md\ntmp\n"
+	dir = writeContext(t, files)
+	defer os.RemoveAll(dir)
+	got = tarNames(t, dir, "build/Dockerfile")
+	want = []string{".dockerignore", "build/Dockerfile", "docs/a/keep.md", "vendor/lib/LICENSE"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("with exceptions: got %v, want %v", got, want)
+	}
+}
+
+func TestCouldMatchBelow(t *testing.T) {
+
+	tests := []struct {
+		pattern string
+		dir     string
+		could   bool
+	}{
+		{"vendor/*/LICENSE", "vendor", true},
+		{"vendor/*/LICENSE", "vendor/lib", true},
+		{"vendor/*/LICENSE", "docs", false},
+		{"vendor/lib", "vendor/lib", false},
+		{"**/keep.md", "docs/a", true},
+		{"docs/**", "docs", true},
+		{"doc?/a/*.md", "docs/a", true},
+	}
+	for _, tt := range tests {
+		if got := couldMatchBelow(strings.Split(tt.pattern, "/"), strings.Split(tt.dir, "/")); got != tt.could {
+			t.Errorf("couldMatchBelow(%q, %q) = %v, want %v", tt.pattern, tt.dir, got, tt.could)
+		}
+	}
+}
+
+func TestBuildOptions(t *testing.T) {
+
+	remote := BuildOptions{Remote: "https://github.com/stevebargelt/simpleDotNet.git", Ref: "develop", Subdir: "docker/slave"}
+	if got := remote.RemoteContext(); got != "https://github.com/stevebargelt/simpleDotNet.git#develop:docker/slave" {
+		t.Errorf("got %q", got)
+	}
+	if got := (BuildOptions{Remote: "https://example.com/r.git#v1:x"}).RemoteContext(); got != "https://example.com/r.git#v1:x" {
+		t.Errorf("an explicit fragment should be kept, got %q", got)
+	}
+	if got := (BuildOptions{Remote: "https://example.com/r.git", Subdir: "x"}).RemoteContext(); got != "https://example.com/r.git#:x" {
+		t.Errorf("got %q", got)
+	}
+
+	args := (BuildOptions{BuildArgs: map[string]string{"DOTNET_VERSION": "2.1", "PROXY": ""}}).imageBuildOptions(nil).BuildArgs
+	if len(args) != 2 || *args["DOTNET_VERSION"] != "2.1" || *args["PROXY"] != "" {
+		t.Errorf("unexpected build args %v", args)
+	}
+
+	invalid := []BuildOptions{
+		{},
+		{Dir: ".", Remote: "https://example.com/r.git"},
+		{Dir: ".", Ref: "master"},
+	}
+	for _, o := range invalid {
+		if err := o.validate(); err == nil {
+			t.Errorf("%+v: expected an error", o)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package docker
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// buildParamsKey is the context key of the build parameters dockhand sends itself
+type buildParamsKey struct{}
+
+// withBuildParams returns a copy of ctx carrying build parameters the Docker client has no
+// option for, such as target, for buildParamsTransport to add to the build request
+func withBuildParams(ctx context.Context, params url.Values) context.Context {
+	return context.WithValue(ctx, buildParamsKey{}, params)
+}
+
+// buildParamsTransport adds the build parameters in the context of a /build request to its query
+type buildParamsTransport struct {
+	base http.RoundTripper
+}
+
+func (t *buildParamsTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+
+	params, _ := r.Context().Value(buildParamsKey{}).(url.Values)
+	if len(params) == 0 || !strings.HasSuffix(r.URL.Path, "/build") {
+		return t.base.RoundTrip(r)
+	}
+
+	// a RoundTripper must not change the request it is given
+	request := new(http.Request)
+	*request = *r
+	u := *r.URL
+	query := u.Query()
+	for name, values := range params {
+		query[name] = values
+	}
+	u.RawQuery = query.Encode()
+	request.URL = &u
+	return t.base.RoundTrip(request)
+}
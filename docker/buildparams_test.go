@@ -0,0 +1,44 @@
+package docker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+func TestBuildParamsTransport(t *testing.T) {
+
+	var queries []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query())
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &buildParamsTransport{base: http.DefaultTransport}}
+	ctx := withBuildParams(context.Background(), url.Values{"target": {"test"}})
+	for _, path := range []string{"/v1.26/build?t=example%2Fslave&rm=1", "/v1.26/images/create?fromImage=slave"} {
+		request, err := http.NewRequest("POST", server.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err := ctxhttp.Do(ctx, client, request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+	}
+
+	if len(queries) != 2 {
+		t.Fatalf("%d requests", len(queries))
+	}
+	if build := queries[0]; build.Get("target") != "test" || build.Get("t") != "example/slave" || build.Get("rm") != "1" {
+		t.Errorf("build query %v", build)
+	}
+	if _, ok := queries[1]["target"]; ok {
+		t.Errorf("target added to another request: %v", queries[1])
+	}
+}
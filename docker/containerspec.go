@@ -0,0 +1,115 @@
+package docker
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/strslice"
+	"github.com/docker/go-connections/nat"
+	units "github.com/docker/go-units"
+)
+
+// ContainerSpec says how to run a container, like the options of docker run do.
+// Empty fields keep the image's own settings and Docker's defaults.
+type ContainerSpec struct {
+	Image string
+	Name  string
+
+	Entrypoint []string
+	Cmd        []string
+	// Env are KEY=VALUE variables added to the image's environment
+	Env     []string
+	User    string
+	Workdir string
+	Labels  map[string]string
+
+	// Memory is a size limit such as 512m or 2g
+	Memory string
+	// CPUs is how many CPUs the container may use, e.g. 1.5
+	CPUs float64
+	// Tmpfs are tmpfs mounts as path or path:options, e.g. /tmp:rw,size=64m
+	Tmpfs []string
+	// Mounts are bind mounts and volumes as source:target[:options], e.g. /var/run/docker.sock:/var/run/docker.sock
+	Mounts []string
+	// Networks are the networks the container joins, it is created on the first one
+	Networks []string
+	// Ports are container ports published on ports of the Docker host that Docker picks, e.g. 22/tcp
+	Ports []string
+}
+
+// cpuPeriod is the CFS scheduler period CPUs is turned into a quota of, docker run --cpus uses the same
+const cpuPeriod = 100000
+
+// configs turns the spec into the configurations of the Docker create call
+func (s ContainerSpec) configs() (*container.Config, *container.HostConfig, *network.NetworkingConfig, error) {
+
+	if s.Image == "" {
+		return nil, nil, nil, errors.New("no image to create the container from")
+	}
+
+	config := &container.Config{
+		Image:      s.Image,
+		Env:        s.Env,
+		User:       s.User,
+		WorkingDir: s.Workdir,
+		Labels:     s.Labels,
+	}
+	if len(s.Entrypoint) > 0 {
+		config.Entrypoint = strslice.StrSlice(s.Entrypoint)
+	}
+	if len(s.Cmd) > 0 {
+		config.Cmd = strslice.StrSlice(s.Cmd)
+	}
+
+	hostConfig := &container.HostConfig{Binds: s.Mounts}
+	if s.Memory != "" {
+		memory, err := units.RAMInBytes(s.Memory)
+		if err != nil {
+			return nil, nil, nil, errors.New("invalid memory limit " + s.Memory + ": " + err.Error())
+		}
+		hostConfig.Memory = memory
+	}
+	if s.CPUs < 0 {
+		return nil, nil, nil, errors.New("the number of CPUs cannot be negative")
+	}
+	if s.CPUs > 0 {
+		hostConfig.CPUPeriod = cpuPeriod
+		hostConfig.CPUQuota = int64(s.CPUs * cpuPeriod)
+	}
+	if len(s.Tmpfs) > 0 {
+		hostConfig.Tmpfs = make(map[string]string, len(s.Tmpfs))
+		for _, tmpfs := range s.Tmpfs {
+			parts := strings.SplitN(tmpfs, ":", 2)
+			options := ""
+			if len(parts) == 2 {
+				options = parts[1]
+			}
+			hostConfig.Tmpfs[parts[0]] = options
+		}
+	}
+	for _, mount := range s.Mounts {
+		if len(strings.Split(mount, ":")) < 2 {
+			return nil, nil, nil, errors.New("invalid mount " + mount + ", want source:target[:options]")
+		}
+	}
+
+	if len(s.Ports) > 0 {
+		exposed, bindings, err := nat.ParsePortSpecs(s.Ports)
+		if err != nil {
+			return nil, nil, nil, errors.New("invalid port: " + err.Error())
+		}
+		config.ExposedPorts = exposed
+		hostConfig.PortBindings = bindings
+	}
+
+	var networkingConfig *network.NetworkingConfig
+	if len(s.Networks) > 0 {
+		hostConfig.NetworkMode = container.NetworkMode(s.Networks[0])
+		networkingConfig = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{s.Networks[0]: {}},
+		}
+	}
+	return config, hostConfig, networkingConfig, nil
+}
@@ -0,0 +1,75 @@
+package docker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestContainerSpecConfigs(t *testing.T) {
+
+	spec := ContainerSpec{
+		Image:      "registry.example.com/slave:1.0",
+		Entrypoint: []string{"/usr/sbin/sshd"},
+		Cmd:        []string{"-D"},
+		Env:        []string{"JAVA_OPTS=-Xmx512m"},
+		User:       "jenkins",
+		Memory:     "512m",
+		CPUs:       1.5,
+		Tmpfs:      []string{"/tmp:rw,size=64m", "/run"},
+		Mounts:     []string{"/var/run/docker.sock:/var/run/docker.sock"},
+		Networks:   []string{"build", "registry"},
+		Ports:      []string{"22/tcp"},
+	}
+	config, hostConfig, networkingConfig, err := spec.configs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Image != spec.Image || config.User != "jenkins" || config.Entrypoint[0] != "/usr/sbin/sshd" || config.Cmd[0] != "-D" {
+		t.Errorf("config = %+v", config)
+	}
+	if hostConfig.Memory != 512*1024*1024 {
+		t.Errorf("memory = %d", hostConfig.Memory)
+	}
+	if hostConfig.CPUPeriod != 100000 || hostConfig.CPUQuota != 150000 {
+		t.Errorf("CPU period %d, quota %d", hostConfig.CPUPeriod, hostConfig.CPUQuota)
+	}
+	if hostConfig.Tmpfs["/tmp"] != "rw,size=64m" || len(hostConfig.Tmpfs) != 2 {
+		t.Errorf("tmpfs = %v", hostConfig.Tmpfs)
+	}
+	if string(hostConfig.NetworkMode) != "build" || networkingConfig.EndpointsConfig["build"] == nil {
+		t.Errorf("network mode %q, endpoints %v", hostConfig.NetworkMode, networkingConfig.EndpointsConfig)
+	}
+	if _, exposed := config.ExposedPorts["22/tcp"]; !exposed || len(hostConfig.PortBindings["22/tcp"]) != 1 {
+		t.Errorf("exposed ports %v, bindings %v", config.ExposedPorts, hostConfig.PortBindings)
+	}
+
+	// the image's own entrypoint and command stay unless the spec overrides them
+	config, _, networkingConfig, err = ContainerSpec{Image: "slave"}.configs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Entrypoint != nil || config.Cmd != nil || networkingConfig != nil {
+		t.Errorf("empty spec config = %+v, %+v", config, networkingConfig)
+	}
+}
+
+func TestContainerSpecInvalid(t *testing.T) {
+
+	tests := []struct {
+		spec ContainerSpec
+		want string
+	}{
+		{ContainerSpec{}, "no image"},
+		{ContainerSpec{Image: "slave", Memory: "lots"}, "invalid memory"},
+		{ContainerSpec{Image: "slave", CPUs: -1}, "negative"},
+		{ContainerSpec{Image: "slave", Mounts: []string{"/data"}}, "invalid mount"},
+		{ContainerSpec{Image: "slave", Ports: []string{"ssh"}}, "invalid port"},
+	}
+	for _, test := range tests {
+		_, _, _, err := test.spec.configs()
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("configs() of %+v = %v, want an error with %q", test.spec, err, test.want)
+		}
+	}
+}
@@ -4,26 +4,51 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 
-	"crypto/tls"
-	"crypto/x509"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
 	dockerClient "github.com/docker/docker/client"
 	"golang.org/x/net/context"
 )
 
-//Host - a Docker Host Client
+//Host - a Docker Host Client.
+//Every method takes a context: cancelling it or letting it time out aborts the call to the Docker host.
 type Host struct {
 	URL       string
-	DockerCli *dockerClient.Client
+	DockerCli APIClient
 	//Logf   LogfCallback
+
+	// ServerAPIVersion is the newest API version the Docker host speaks, set by NegotiateAPIVersion
+	ServerAPIVersion string
+
+	// Labels are added to every container created through the Host, next to ManagedLabel
+	Labels map[string]string
+
+	// containers are the containers created through the Host and not removed yet
+	mu         sync.Mutex
+	containers map[string]bool
+
+	// buildCli sends the build requests, DockerCli when nil
+	buildCli APIClient
+}
+
+// ManagedLabel marks the containers Dockhand creates so leftovers can be found and removed
+const ManagedLabel = "dockhand.managed"
+
+//IsErrNotFound reports whether err is the Docker host saying an image or container does not exist
+func IsErrNotFound(err error) bool {
+	return dockerClient.IsErrNotFound(err)
 }
 
 func BuildAuth(registryUsername, registryPassword, registryURL string) (string, error) {
@@ -38,159 +63,129 @@ func BuildAuth(registryUsername, registryPassword, registryURL string) (string,
 
 }
 
-//New - creates a new Docker Host with given docker host URL and TLS cert file paths
-func NewWithFiles(URL, certFile, keyFile, caFile string) (*Host, error) {
+func newClientFromTransport(url string, transport http.RoundTripper, userAgent string) (*Host, error) {
 
-	//TODO: handle ooverrides when individual file names are sent in
-	url := strings.TrimSuffix(URL, "/")
+	httpCli := &http.Client{Transport: transport}
 
-	// Load client cert
-	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	// requests use MaxAPIVersion until NegotiateAPIVersion settles on what the host speaks
+	defaultHeaders := map[string]string{"User-Agent": userAgent}
+	cli, err := dockerClient.NewClient(url, MaxAPIVersion, httpCli, defaultHeaders)
 	if err != nil {
 		return nil, err
 	}
 
-	// Load CA cert
-	caCert, err := ioutil.ReadFile(caFile)
+	// builds go through a client of their own, its transport adds the build parameters the Docker
+	// client cannot send. The transport is swapped in after NewClient, which only takes an
+	// *http.Transport, and the other client keeps the plain one its hijacked exec connections need.
+	buildHTTPCli := &http.Client{Transport: transport}
+	buildCli, err := dockerClient.NewClient(url, MaxAPIVersion, buildHTTPCli, defaultHeaders)
 	if err != nil {
 		return nil, err
 	}
-	caCertPool := x509.NewCertPool()
-	caCertPool.AppendCertsFromPEM(caCert)
+	buildHTTPCli.Transport = &buildParamsTransport{base: transport}
 
-	// Setup HTTPS client
-	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{cert},
-		RootCAs:      caCertPool,
+	dockerHost := &Host{
+		URL:       url,
+		DockerCli: cli,
+		buildCli:  buildCli,
 	}
 
-	tlsConfig.BuildNameToCertificate()
-	transport := &http.Transport{TLSClientConfig: tlsConfig}
+	return dockerHost, nil
 
-	return newClientFromTransport(url, transport)
 }
 
-//New - creates a new Docker Host with given docker host URL and TLS cert file paths
-func New(URL, tlslocation string) (*Host, error) {
+// NewWithClient creates a Host that talks to the Docker API through cli, e.g. a dockertest.Client
+func NewWithClient(URL string, cli APIClient) *Host {
+	return &Host{URL: URL, DockerCli: cli}
+}
 
-	//TODO: handle optional params for individual file names
-	url := strings.TrimSuffix(URL, "/")
-	tlslocation = strings.TrimSuffix(tlslocation, "/")
+//BuildDockerImage : builds an image tagged imageName (name:tag) on the Docker host from a local directory,
+//tar stream or Git repo as described by options.
+//Build output is passed to progress (which may be nil) as it arrives and the ID of the new image is returned.
+//A failure inside the Dockerfile is returned as a *BuildError.
+func (d *Host) BuildDockerImage(ctx context.Context, imageName string, options BuildOptions, progress ProgressFunc) (string, error) {
 
-	// Load client cert
-	cert, err := tls.LoadX509KeyPair(tlslocation+"/cert.pem", tlslocation+"/key.pem")
-	if err != nil {
-		return nil, err
+	if err := options.validate(); err != nil {
+		return "", err
 	}
-
-	// Load CA cert
-	caCert, err := ioutil.ReadFile(tlslocation + "/ca.pem")
+	if options.Target != "" {
+		// types.ImageBuildOptions has no Target before API 1.29, the build client's transport sends it
+		if err := d.RequireAPIVersion(FeatureBuildTarget); err != nil {
+			return "", errors.New("cannot build target " + options.Target + ": " + err.Error())
+		}
+		ctx = withBuildParams(ctx, url.Values{"target": {options.Target}})
+	}
+	buildContext, err := options.contextReader()
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	caCertPool := x509.NewCertPool()
-	caCertPool.AppendCertsFromPEM(caCert)
-
-	// Setup HTTPS client
-	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{cert},
-		RootCAs:      caCertPool,
+	if buildContext != nil {
+		defer buildContext.Close()
 	}
 
-	tlsConfig.BuildNameToCertificate()
-	transport := &http.Transport{TLSClientConfig: tlsConfig}
-
-	return newClientFromTransport(url, transport)
-}
-
-//NewInsecure - creates a new Host with given docker host: this is not secure... please know what you are doing!
-func NewInsecure(URL string) (*Host, error) {
-
-	url := strings.TrimSuffix(URL, "/")
+	tags := []string{imageName}
 
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
+	buildCli := d.DockerCli
+	if d.buildCli != nil {
+		buildCli = d.buildCli
 	}
-
-	return newClientFromTransport(url, transport)
-}
-
-func newClientFromTransport(url string, transport http.RoundTripper) (*Host, error) {
-
-	httpCli := &http.Client{Transport: transport}
-
-	defaultHeaders := map[string]string{"User-Agent": "engine-api-cli-1.0"}
-	cli, err := dockerClient.NewClient(url, "v1.24", httpCli, defaultHeaders)
+	buildResponse, err := buildCli.ImageBuild(ctx, buildContext, options.imageBuildOptions(tags))
 	if err != nil {
-		return nil, err
-	}
-
-	dockerHost := &Host{
-		URL:       url,
-		DockerCli: cli,
+		return "", fmt.Errorf("cannot build %s from %s: %v", imageName, options.describe(), err)
 	}
+	defer buildResponse.Body.Close()
 
-	return dockerHost, nil
-
-}
-
-//BuildDockerImage : given an imageName (name:tag) and Git repo will build an image on the Docker host with the imageName
-func (d *Host) BuildDockerImage(imageName, repo string) error {
-
-	tags := []string{imageName}
-
-	options := types.ImageBuildOptions{RemoteContext: repo, Tags: tags}
-
-	buildResponse, err := d.DockerCli.ImageBuild(context.Background(), nil, options)
+	imageID, err := readBuildOutput(buildResponse.Body, progress)
 	if err != nil {
-		fmt.Println("Cannot build image ", imageName, " from repo ", repo, " | err=", err)
-		return err
+		return "", err
 	}
 
-	buildResponse.Body.Close()
-	return nil
+	// older daemons only report the short ID, so ask for the full one
+	if !strings.HasPrefix(imageID, "sha256:") {
+		image, _, err := d.DockerCli.ImageInspectWithRaw(ctx, imageName)
+		if err != nil {
+			return "", fmt.Errorf("build of %s finished but the image cannot be found: %v", imageName, err)
+		}
+		imageID = image.ID
+	}
+	return imageID, nil
 }
 
-func (d *Host) PushDockerImage(imageName, registryUsername, registryPassword, registryURL string) error {
+//PushDockerImage pushes imageName to the registry, passing push progress to progress (which may be nil).
+//It returns the digest of the pushed manifest, a failed push is returned as a *PushError.
+func (d *Host) PushDockerImage(ctx context.Context, imageName, registryUsername, registryPassword, registryURL string, progress ProgressFunc) (string, error) {
 
 	encodedAuth, err := BuildAuth(registryUsername, registryPassword, registryURL)
 	if err != nil {
-		return err
+		return "", err
 	}
 	options := types.ImagePushOptions{RegistryAuth: encodedAuth}
-	pushResponse, err := d.DockerCli.ImagePush(context.Background(), imageName, options)
+	pushResponse, err := d.DockerCli.ImagePush(ctx, imageName, options)
 	if err != nil {
-		fmt.Println("Cannot push image ", imageName, " | err=", err)
-		return err
+		return "", fmt.Errorf("cannot push %s: %v", imageName, err)
 	}
-	body, err := ioutil.ReadAll(pushResponse)
-	fmt.Println("*******************************")
-	fmt.Println("The Body of the push response is: ", string(body))
-	fmt.Println("*******************************")
-	return nil
+	defer pushResponse.Close()
+
+	return readPushOutput(imageName, pushResponse, progress)
 }
 
-//GetDockerImage given imageName and the registry information returns a docker image
-func (d *Host) GetDockerImage(imageName, registryUsername, registryPassword, registryURL string) (*types.ImageInspect, error) {
+//GetDockerImage given imageName and the registry information returns a docker image.
+//When the pull fails but the Docker host has the image, that image is returned along with the
+//pull error as pullErr, for the caller to report.
+func (d *Host) GetDockerImage(ctx context.Context, imageName, registryUsername, registryPassword, registryURL string) (image *types.ImageInspect, pullErr error, err error) {
 
-	//TODO: log
-	//fmt.Println("Looking for image", imageName, "...")
-	image, _, imageErr := d.DockerCli.ImageInspectWithRaw(context.TODO(), imageName)
-	newImage, err := d.pullImage(imageName, registryUsername, registryPassword, registryURL)
+	local, _, imageErr := d.DockerCli.ImageInspectWithRaw(ctx, imageName)
+	newImage, err := d.pullImage(ctx, imageName, registryUsername, registryPassword, registryURL)
 	if err != nil {
 		if imageErr == nil {
-			fmt.Println("Cannot pull the latest version of image", imageName, ":", err)
-			fmt.Println("Locally found image will be used instead.")
-			return &image, nil
+			return &local, err, nil
 		}
-		return nil, err
+		return nil, nil, err
 	}
-	return newImage, nil
+	return newImage, nil, nil
 }
 
-func (d *Host) pullImage(imageName, registryUsername, registryPassword, registryURL string) (*types.ImageInspect, error) {
+func (d *Host) pullImage(ctx context.Context, imageName, registryUsername, registryPassword, registryURL string) (*types.ImageInspect, error) {
 
 	//TODO: log
 	//fmt.Println("Pulling docker image", imageName, "...")
@@ -213,7 +208,7 @@ func (d *Host) pullImage(imageName, registryUsername, registryPassword, registry
 	}
 
 	options := types.ImagePullOptions{RegistryAuth: encodedAuth}
-	readCloser, err := d.DockerCli.ImagePull(context.Background(), ref, options)
+	readCloser, err := d.DockerCli.ImagePull(ctx, ref, options)
 	if err != nil {
 		return nil, err
 	}
@@ -223,26 +218,95 @@ func (d *Host) pullImage(imageName, registryUsername, registryPassword, registry
 		return nil, fmt.Errorf("Failed to pull image: %s: %s", ref, err)
 	}
 
-	image, _, err := d.DockerCli.ImageInspectWithRaw(context.Background(), imageName)
+	image, _, err := d.DockerCli.ImageInspectWithRaw(ctx, imageName)
 	return &image, err
 }
 
-//CreateContainer - creates a container named containerName given an imageName
-func (d *Host) CreateContainer(imageName, containerName string) (*container.ContainerCreateCreatedBody, error) {
+// ImageInspect returns the details of an image on the Docker host given an imageName
+func (d *Host) ImageInspect(ctx context.Context, imageName string) (types.ImageInspect, error) {
+
+	image, _, err := d.DockerCli.ImageInspectWithRaw(ctx, imageName)
+	return image, err
+
+}
+
+// ImageDigest returns the registry digest of imageName as recorded on the Docker host
+// when the image was pushed or pulled, or "" if it has none
+func (d *Host) ImageDigest(ctx context.Context, imageName string) (string, error) {
+
+	image, err := d.ImageInspect(ctx, imageName)
+	if err != nil {
+		return "", err
+	}
+	repo := repository(imageName)
+	for _, repoDigest := range image.RepoDigests {
+		if strings.HasPrefix(repoDigest, repo+"@") {
+			return strings.TrimPrefix(repoDigest, repo+"@"), nil
+		}
+	}
+	return "", nil
+
+}
+
+//CreateContainer - creates a container as described by spec and connects it to the networks it names
+func (d *Host) CreateContainer(ctx context.Context, spec ContainerSpec) (*container.ContainerCreateCreatedBody, error) {
+
+	config, hostConfig, networkingConfig, err := spec.configs()
+	if err != nil {
+		return nil, err
+	}
+	created, err := d.createContainer(ctx, config, hostConfig, networkingConfig, spec.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// a container is created on one network, the others it joins before it starts
+	for i := 1; i < len(spec.Networks); i++ {
+		if err := d.DockerCli.NetworkConnect(ctx, spec.Networks[i], created.ID, nil); err != nil {
+			d.removeDetached(created.ID)
+			return nil, errors.New("cannot connect the container to network " + spec.Networks[i] + ": " + err.Error())
+		}
+	}
+	return created, nil
+
+}
+
+// createContainer creates a container labeled as Dockhand's and remembers it until it is removed
+func (d *Host) createContainer(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, containerName string) (*container.ContainerCreateCreatedBody, error) {
 
-	container, err := d.DockerCli.ContainerCreate(context.Background(), &container.Config{Image: imageName}, nil, nil, containerName)
+	config.Labels = d.containerLabels(config.Labels)
+	container, err := d.DockerCli.ContainerCreate(ctx, config, hostConfig, networkingConfig, containerName)
 	if err != nil {
 		return nil, err
 	}
 
+	d.mu.Lock()
+	if d.containers == nil {
+		d.containers = map[string]bool{}
+	}
+	d.containers[container.ID] = true
+	d.mu.Unlock()
+
 	return &container, nil
 
 }
 
+func (d *Host) containerLabels(labels map[string]string) map[string]string {
+
+	all := map[string]string{ManagedLabel: "true"}
+	for name, value := range d.Labels {
+		all[name] = value
+	}
+	for name, value := range labels {
+		all[name] = value
+	}
+	return all
+}
+
 //StartContainer - runs a container named containerName given an imageName
-func (d *Host) StartContainer(containerID string) error {
+func (d *Host) StartContainer(ctx context.Context, containerID string) error {
 
-	err := d.DockerCli.ContainerStart(context.Background(), containerID, types.ContainerStartOptions{})
+	err := d.DockerCli.ContainerStart(ctx, containerID, types.ContainerStartOptions{})
 	if err != nil {
 		return err
 	}
@@ -252,15 +316,52 @@ func (d *Host) StartContainer(containerID string) error {
 }
 
 // ContainerInspect returns the deatils of a container given a containerID
-func (d *Host) ContainerInspect(containerID string) (types.ContainerJSON, error) {
+func (d *Host) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
 
-	return d.DockerCli.ContainerInspect(context.Background(), containerID)
+	return d.DockerCli.ContainerInspect(ctx, containerID)
 
 }
 
 //ContainerRemove - removes a container give a containerID
-func (d *Host) ContainerRemove(id string) error {
+func (d *Host) ContainerRemove(ctx context.Context, id string) error {
+
+	err := d.DockerCli.ContainerRemove(ctx, id, types.ContainerRemoveOptions{RemoveVolumes: true, Force: true})
+	if err == nil || IsErrNotFound(err) {
+		d.mu.Lock()
+		delete(d.containers, id)
+		d.mu.Unlock()
+	}
+	return err
 
-	return d.DockerCli.ContainerRemove(context.Background(), id, types.ContainerRemoveOptions{RemoveVolumes: true, Force: true})
+}
+
+// RemoveContainers removes every container created through the Host that is still around,
+// it is what makes sure an interrupted or failed run does not leave containers behind
+func (d *Host) RemoveContainers(ctx context.Context) error {
+
+	d.mu.Lock()
+	ids := make([]string, 0, len(d.containers))
+	for id := range d.containers {
+		ids = append(ids, id)
+	}
+	d.mu.Unlock()
 
+	var firstErr error
+	for _, id := range ids {
+		if err := d.ContainerRemove(ctx, id); err != nil && !IsErrNotFound(err) && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// ManagedContainers lists the containers Dockhand created, running or not, that have all the given labels
+func (d *Host) ManagedContainers(ctx context.Context, labels map[string]string) ([]types.Container, error) {
+
+	args := filters.NewArgs()
+	args.Add("label", ManagedLabel+"=true")
+	for name, value := range labels {
+		args.Add("label", name+"="+value)
+	}
+	return d.DockerCli.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: args})
 }
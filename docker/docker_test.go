@@ -1,10 +1,16 @@
 package docker
 
 import (
+	"errors"
 	"flag"
-	"fmt"
 	"os"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/stevebargelt/Dockhand/docker/dockertest"
+	"golang.org/x/net/context"
 )
 
 func TestMain(m *testing.M) {
@@ -18,13 +24,316 @@ func TestMain(m *testing.M) {
 
 func TestGetDockerImage(t *testing.T) {
 
-	fmt.Println("test")
+	fake := dockertest.New("")
+	d := NewWithClient("fake", fake)
+	ctx := context.Background()
+
+	published := fake.Publish("registry.example.com/slave:1.0", nil)
+	image, pullErr, err := d.GetDockerImage(ctx, "registry.example.com/slave:1.0", "user", "secret", "registry.example.com")
+	if err != nil || pullErr != nil || image.ID != published {
+		t.Fatalf("pull: got %v, %v, %v, want image %s", image, pullErr, err, published)
+	}
+
+	// a local image is used when the registry cannot be reached
+	local := fake.AddImage("registry.example.com/local", nil)
+	fake.Fail("ImagePull", errors.New("registry unavailable"))
+	image, pullErr, err = d.GetDockerImage(ctx, "registry.example.com/local", "user", "secret", "registry.example.com")
+	if err != nil || image.ID != local {
+		t.Errorf("local fallback: got %v, %v, want image %s", image, err, local)
+	}
+	if pullErr == nil || !strings.Contains(pullErr.Error(), "registry unavailable") {
+		t.Errorf("local fallback: pull error %v", pullErr)
+	}
 
+	if _, _, err := d.GetDockerImage(ctx, "registry.example.com/missing", "user", "secret", "registry.example.com"); err == nil {
+		t.Error("missing image: expected an error")
+	}
 }
 
-func ExampleGetDockerImage() {
+func ExampleHost_GetDockerImage() {
 	//numbers := []int{5, 5, 5}
 	//fmt.Println(Sum(numbers))
-	// Output:
-	// 15
+}
+
+func TestContainerLabels(t *testing.T) {
+
+	d := &Host{Labels: map[string]string{"dockhand.test-label": "TeamA", "team": "host"}}
+	labels := d.containerLabels(map[string]string{"team": "container"})
+
+	want := map[string]string{ManagedLabel: "true", "dockhand.test-label": "TeamA", "team": "container"}
+	if len(labels) != len(want) {
+		t.Fatalf("labels = %v, want %v", labels, want)
+	}
+	for name, value := range want {
+		if labels[name] != value {
+			t.Errorf("label %s = %q, want %q", name, labels[name], value)
+		}
+	}
+}
+
+func TestBuildDockerImage(t *testing.T) {
+
+	fake := dockertest.New("")
+	d := NewWithClient("fake", fake)
+	ctx := context.Background()
+	options := BuildOptions{Remote: "https://github.com/example/slave.git", Ref: "master", Labels: map[string]string{"dockhand.label": "TeamA"}}
+
+	var messages int
+	id, err := d.BuildDockerImage(ctx, "example/slave:1.0", options, func(JSONMessage) { messages++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	image, err := d.ImageInspect(ctx, "example/slave:1.0")
+	if err != nil || image.ID != id {
+		t.Errorf("built image %s, tagged image %v, %v", id, image, err)
+	}
+	if image.Config.Labels["dockhand.label"] != "TeamA" {
+		t.Errorf("image labels %v", image.Config.Labels)
+	}
+	builds := fake.Builds()
+	if len(builds) != 1 || builds[0].RemoteContext != "https://github.com/example/slave.git#master" {
+		t.Errorf("builds %+v", builds)
+	}
+	if messages == 0 {
+		t.Error("no build output passed to progress")
+	}
+
+	fake.BuildError = "The command '/bin/sh -c exit 1' returned a non-zero code: 1"
+	_, err = d.BuildDockerImage(ctx, "example/slave:1.1", options, nil)
+	buildErr, ok := err.(*BuildError)
+	if !ok || buildErr.Step != "Step 1/2 : FROM scratch" || buildErr.Message != fake.BuildError {
+		t.Errorf("failed build: got %#v", err)
+	}
+}
+
+func TestBuildTargetNeedsNewerAPI(t *testing.T) {
+
+	options := BuildOptions{Remote: "https://github.com/example/slave.git", Target: "test"}
+
+	// dockhand sends the target itself, so a host speaking 1.29 is enough though the client stops at MaxAPIVersion
+	d := NewWithClient("fake", dockertest.New("1.30"))
+	if err := d.NegotiateAPIVersion(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if d.APIVersion() != MaxAPIVersion || d.ServerAPIVersion != "1.30" {
+		t.Errorf("negotiated %s with a 1.30 host (%s)", d.APIVersion(), d.ServerAPIVersion)
+	}
+	if _, err := d.BuildDockerImage(context.Background(), "example/slave", options, nil); err != nil {
+		t.Errorf("build target with a 1.30 host: %v", err)
+	}
+
+	d = NewWithClient("fake", dockertest.New("1.25"))
+	if err := d.NegotiateAPIVersion(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	_, err := d.BuildDockerImage(context.Background(), "example/slave", options, nil)
+	if err == nil || !strings.Contains(err.Error(), FeatureBuildTarget) || !strings.Contains(err.Error(), "only speaks 1.25") {
+		t.Errorf("build target with a 1.25 host: got %v", err)
+	}
+}
+
+func TestPushDockerImage(t *testing.T) {
+
+	fake := dockertest.New("")
+	d := NewWithClient("fake", fake)
+	ctx := context.Background()
+	fake.AddImage("registry.example.com/slave:1.0", nil)
+
+	digest, err := d.PushDockerImage(ctx, "registry.example.com/slave:1.0", "user", "secret", "registry.example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(digest, "sha256:") {
+		t.Errorf("digest %q", digest)
+	}
+	if recorded, err := d.ImageDigest(ctx, "registry.example.com/slave:1.0"); err != nil || recorded != digest {
+		t.Errorf("ImageDigest = %q, %v, want %q", recorded, err, digest)
+	}
+
+	if _, err := d.PushDockerImage(ctx, "registry.example.com/missing", "user", "secret", "registry.example.com", nil); err == nil {
+		t.Error("push of a missing image: expected an error")
+	}
+
+	fake.Fail("ImagePush", errors.New("connection refused"))
+	if _, err := d.PushDockerImage(ctx, "registry.example.com/slave:1.0", "user", "secret", "registry.example.com", nil); err == nil || err.Error() != "cannot push registry.example.com/slave:1.0: connection refused" {
+		t.Errorf("unreachable Docker host: got %v", err)
+	}
+	fake.Fail("ImagePush", nil)
+
+	fake.PushError = "unauthorized: authentication required"
+	_, err = d.PushDockerImage(ctx, "registry.example.com/slave:1.0", "user", "wrong", "registry.example.com", nil)
+	pushErr, ok := err.(*PushError)
+	if !ok || pushErr.Layer == "" || pushErr.Message != fake.PushError {
+		t.Errorf("rejected push: got %#v", err)
+	}
+}
+
+func TestCreateContainer(t *testing.T) {
+
+	fake := dockertest.New("")
+	d := NewWithClient("fake", fake)
+	d.Labels = map[string]string{"dockhand.run-id": "0123abcd"}
+	ctx := context.Background()
+	fake.AddImage("example/slave", &container.Config{Labels: map[string]string{"dockhand.label": "TeamA"}})
+
+	spec := ContainerSpec{
+		Image:    "example/slave",
+		Name:     "DockhandTestingTeamA_0123abcd",
+		Memory:   "512m",
+		Networks: []string{"build", "registry"},
+	}
+	created, err := d.CreateContainer(ctx, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctr, ok := fake.Container(spec.Name)
+	if !ok || ctr.ID != created.ID {
+		t.Fatalf("container %s not found by name", created.ID)
+	}
+	for name, value := range map[string]string{ManagedLabel: "true", "dockhand.run-id": "0123abcd", "dockhand.label": "TeamA"} {
+		if ctr.Config.Labels[name] != value {
+			t.Errorf("label %s = %q, want %q", name, ctr.Config.Labels[name], value)
+		}
+	}
+	if strings.Join(ctr.Networks, ",") != "build,registry" {
+		t.Errorf("networks %v", ctr.Networks)
+	}
+	if ctr.HostConfig.Memory != 512*1024*1024 {
+		t.Errorf("memory %d", ctr.HostConfig.Memory)
+	}
+
+	if _, err := d.CreateContainer(ctx, spec); err == nil {
+		t.Error("second container with the same name: expected an error")
+	}
+	if _, err := d.CreateContainer(ctx, ContainerSpec{Image: "example/missing"}); !IsErrNotFound(err) {
+		t.Errorf("missing image: got %v, want a not found error", err)
+	}
+
+	if err := d.StartContainer(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	found, err := d.ManagedContainers(ctx, map[string]string{"dockhand.run-id": "0123abcd"})
+	if err != nil || len(found) != 1 {
+		t.Errorf("managed containers of the run: %v, %v", found, err)
+	}
+	if found, _ := d.ManagedContainers(ctx, map[string]string{"dockhand.run-id": "ffffffff"}); len(found) != 0 {
+		t.Errorf("managed containers of another run: %v", found)
+	}
+
+	if err := d.RemoveContainers(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if left := fake.Containers(); len(left) != 0 {
+		t.Errorf("containers left after RemoveContainers: %v", left)
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+
+	fake := dockertest.New("")
+	d := NewWithClient("fake", fake)
+	ctx := context.Background()
+	fake.AddImage("example/slave", nil)
+
+	var cmd []string
+	fake.Run = func(c *dockertest.Container) dockertest.Result {
+		cmd = append(append([]string{}, c.Config.Entrypoint...), c.Config.Cmd...)
+		return dockertest.Result{Stdout: "1.8.0\n", Stderr: "warning\n", ExitCode: 3}
+	}
+	result, err := d.RunCommand(ctx, "example/slave", []string{"java", "-version"}, nil, "jenkins", "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(cmd, " ") != "java -version" {
+		t.Errorf("ran %v", cmd)
+	}
+	if result.Stdout != "1.8.0\n" || result.Stderr != "warning\n" || result.StatusCode != 3 {
+		t.Errorf("result %+v", result)
+	}
+	if left := fake.Containers(); len(left) != 0 {
+		t.Errorf("containers left after the command: %v", left)
+	}
+
+	fake.Run = func(c *dockertest.Container) dockertest.Result { return dockertest.Result{Running: true} }
+	_, err = d.RunCommand(ctx, "example/slave", []string{"sleep", "600"}, nil, "", "", 10*time.Millisecond)
+	if _, ok := err.(*WaitTimeoutError); !ok {
+		t.Errorf("hanging command: got %v, want a *WaitTimeoutError", err)
+	}
+	if left := fake.Containers(); len(left) != 0 {
+		t.Errorf("containers left after the timeout: %v", left)
+	}
+}
+
+func TestExec(t *testing.T) {
+
+	fake := dockertest.New("")
+	d := NewWithClient("fake", fake)
+	ctx := context.Background()
+	fake.AddImage("example/slave", nil)
+
+	var cmd []string
+	fake.Exec = func(c *dockertest.Container, command []string) dockertest.Result {
+		cmd = command
+		return dockertest.Result{Stdout: "/home/jenkins\n", ExitCode: 0}
+	}
+	created, err := d.CreateContainer(ctx, ContainerSpec{Image: "example/slave"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.RemoveContainers(ctx)
+
+	if _, err := d.Exec(ctx, created.ID, []string{"pwd"}, nil, "", ""); err == nil {
+		t.Error("exec in a container that is not running: expected an error")
+	}
+	if err := d.StartContainer(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := d.Exec(ctx, created.ID, []string{"pwd"}, nil, "jenkins", "/home/jenkins")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Stdout != "/home/jenkins\n" || result.StatusCode != 0 {
+		t.Errorf("result %+v", result)
+	}
+	if len(cmd) != 5 || cmd[0] != "sh" || cmd[3] != "/home/jenkins" || cmd[4] != "pwd" {
+		t.Errorf("workdir not applied: ran %q", cmd)
+	}
+}
+
+func TestWaitContainer(t *testing.T) {
+
+	fake := dockertest.New("")
+	d := NewWithClient("fake", fake)
+	ctx := context.Background()
+	fake.AddImage("example/slave", nil)
+	fake.Run = func(c *dockertest.Container) dockertest.Result {
+		return dockertest.Result{ExitCode: 137, OOMKilled: true}
+	}
+
+	created, err := d.CreateContainer(ctx, ContainerSpec{Image: "example/slave"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.RemoveContainers(ctx)
+	if err := d.StartContainer(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := d.WaitContainer(ctx, created.ID, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.StatusCode != 137 || !result.OOMKilled {
+		t.Errorf("result %+v", result)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	fake.Run = nil
+	running, _ := d.CreateContainer(ctx, ContainerSpec{Image: "example/slave"})
+	d.StartContainer(ctx, running.ID)
+	if _, err := d.WaitContainer(cancelled, running.ID, 0); err != context.Canceled {
+		t.Errorf("cancelled wait: got %v", err)
+	}
 }
@@ -0,0 +1,804 @@
+// Package dockertest is an in-memory Docker host for testing code that uses docker.Host without a daemon
+package dockertest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/pkg/stdcopy"
+	"golang.org/x/net/context"
+)
+
+// APIVersion is the Docker API version the Client reports unless told otherwise
+const APIVersion = "1.26"
+
+// Result is what a container or a command run in one does
+type Result struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	// OOMKilled makes the container look killed for running out of memory
+	OOMKilled bool
+	// Running keeps a container running after it wrote its output, until it is removed
+	Running bool
+}
+
+// Container is a container on the fake Docker host
+type Container struct {
+	ID         string
+	Name       string
+	Config     *container.Config
+	HostConfig *container.HostConfig
+	Networks   []string
+	// Started is set once the container was started and Result is what it did
+	Started bool
+	Result  Result
+
+	startedAt  time.Time
+	finishedAt time.Time
+	exited     chan struct{}
+}
+
+func (c *Container) running() bool {
+
+	if !c.Started {
+		return false
+	}
+	select {
+	case <-c.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+func (c *Container) state() string {
+
+	switch {
+	case c.running():
+		return "running"
+	case c.Started:
+		return "exited"
+	}
+	return "created"
+}
+
+type execInstance struct {
+	containerID string
+	config      types.ExecConfig
+	result      *Result
+}
+
+// Client fakes the Docker API a docker.Host uses, keeping images, containers and the registry in memory.
+// The zero value is not usable, create one with New.
+type Client struct {
+	// Run decides what a started container does, when nil containers keep running until removed
+	Run func(c *Container) Result
+	// Exec decides what a command run in a container does, when nil it exits 0 without output
+	Exec func(c *Container, cmd []string) Result
+	// BuildError and PushError make every build or push fail with that message
+	BuildError string
+	PushError  string
+
+	mu         sync.Mutex
+	apiVersion string
+	version    string
+	next       int
+	images     map[string]*types.ImageInspect
+	tags       map[string]string
+	registry   map[string]string
+	containers map[string]*Container
+	execs      map[string]*execInstance
+	failures   map[string]error
+	builds     []types.ImageBuildOptions
+	pushes     []string
+}
+
+// New returns a fake Docker host without images or containers that speaks apiVersion
+// (APIVersion when empty)
+func New(apiVersion string) *Client {
+
+	if apiVersion == "" {
+		apiVersion = APIVersion
+	}
+	return &Client{
+		apiVersion: apiVersion,
+		version:    apiVersion,
+		images:     map[string]*types.ImageInspect{},
+		tags:       map[string]string{},
+		registry:   map[string]string{},
+		containers: map[string]*Container{},
+		execs:      map[string]*execInstance{},
+		failures:   map[string]error{},
+	}
+}
+
+// notFoundError is how the Docker client reports a missing image or container, docker.IsErrNotFound knows it
+type notFoundError struct{ message string }
+
+func (e notFoundError) Error() string  { return "Error: " + e.message }
+func (e notFoundError) NotFound() bool { return true }
+
+// Fail makes every call of the named API method, e.g. "ImagePull", return err until it is called with nil
+func (c *Client) Fail(method string, err error) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		delete(c.failures, method)
+		return
+	}
+	c.failures[method] = err
+}
+
+func (c *Client) failure(method string) error {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.failures[method]
+}
+
+func (c *Client) newID() string {
+
+	c.next++
+	sum := sha256.Sum256([]byte(fmt.Sprintf("dockertest %d", c.next)))
+	return fmt.Sprintf("%x", sum)
+}
+
+// normalize adds the latest tag to references without a tag or digest
+func normalize(ref string) string {
+
+	if strings.Contains(ref, "@") {
+		return ref
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		return ref
+	}
+	return ref + ":latest"
+}
+
+// repository is ref without its tag or digest
+func repository(ref string) string {
+
+	if i := strings.Index(ref, "@"); i >= 0 {
+		return ref[:i]
+	}
+	return strings.TrimSuffix(normalize(ref), ":"+tag(ref))
+}
+
+func tag(ref string) string {
+
+	ref = normalize(ref)
+	return ref[strings.LastIndex(ref, ":")+1:]
+}
+
+// AddImage puts an image tagged ref on the fake Docker host and returns its ID
+func (c *Client) AddImage(ref string, config *container.Config) string {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.addImage([]string{ref}, config)
+}
+
+// Publish puts an image tagged ref in the fake registry, where pulls find it, and returns its ID
+func (c *Client) Publish(ref string, config *container.Config) string {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := c.addImage(nil, config)
+	c.registry[normalize(ref)] = id
+	return id
+}
+
+func (c *Client) addImage(refs []string, config *container.Config) string {
+
+	if config == nil {
+		config = &container.Config{}
+	}
+	id := "sha256:" + c.newID()
+	c.images[id] = &types.ImageInspect{ID: id, Config: config, Created: time.Now().UTC().Format(time.RFC3339Nano)}
+	for _, ref := range refs {
+		c.tag(ref, id)
+	}
+	return id
+}
+
+// tag points ref at the image id, moving it off the image it tagged before
+func (c *Client) tag(ref, id string) {
+
+	ref = normalize(ref)
+	if old, ok := c.images[c.tags[ref]]; ok {
+		old.RepoTags = without(old.RepoTags, ref)
+	}
+	c.tags[ref] = id
+	image := c.images[id]
+	image.RepoTags = append(without(image.RepoTags, ref), ref)
+}
+
+func without(list []string, s string) []string {
+
+	var out []string
+	for _, item := range list {
+		if item != s {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func (c *Client) image(ref string) (*types.ImageInspect, bool) {
+
+	if image, ok := c.images[ref]; ok {
+		return image, true
+	}
+	if image, ok := c.images["sha256:"+ref]; ok {
+		return image, true
+	}
+	image, ok := c.images[c.tags[normalize(ref)]]
+	return image, ok
+}
+
+// Builds returns the options of every build so far
+func (c *Client) Builds() []types.ImageBuildOptions {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]types.ImageBuildOptions(nil), c.builds...)
+}
+
+// Pushes returns the references pushed so far
+func (c *Client) Pushes() []string {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.pushes...)
+}
+
+// Container returns the container with the given ID or name, if it has not been removed
+func (c *Client) Container(idOrName string) (*Container, bool) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ctr, ok := c.container(idOrName)
+	return ctr, ok
+}
+
+func (c *Client) container(idOrName string) (*Container, bool) {
+
+	if ctr, ok := c.containers[idOrName]; ok {
+		return ctr, true
+	}
+	for _, ctr := range c.containers {
+		if ctr.Name != "" && (ctr.Name == idOrName || "/"+ctr.Name == idOrName) {
+			return ctr, true
+		}
+		if len(idOrName) >= 12 && strings.HasPrefix(ctr.ID, idOrName) {
+			return ctr, true
+		}
+	}
+	return nil, false
+}
+
+// Containers returns the IDs of the containers on the fake Docker host
+func (c *Client) Containers() []string {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ids := make([]string, 0, len(c.containers))
+	for id := range c.containers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// jsonStream encodes messages the way the Docker host streams build, push and pull output
+func jsonStream(messages ...interface{}) io.ReadCloser {
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, m := range messages {
+		encoder.Encode(m)
+	}
+	return ioutil.NopCloser(&buf)
+}
+
+type errorMessage struct {
+	ErrorDetail struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+	Error string `json:"error"`
+}
+
+func streamError(message string) errorMessage {
+
+	m := errorMessage{Error: message}
+	m.ErrorDetail.Message = message
+	return m
+}
+
+// multiplexed frames stdout and stderr the way the Docker host does for containers without a TTY
+func multiplexed(stdout, stderr string) []byte {
+
+	var buf bytes.Buffer
+	if stdout != "" {
+		stdcopy.NewStdWriter(&buf, stdcopy.Stdout).Write([]byte(stdout))
+	}
+	if stderr != "" {
+		stdcopy.NewStdWriter(&buf, stdcopy.Stderr).Write([]byte(stderr))
+	}
+	return buf.Bytes()
+}
+
+// ClientVersion is the API version the Client was told to use
+func (c *Client) ClientVersion() string {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.version
+}
+
+// UpdateClientVersion sets the API version to use
+func (c *Client) UpdateClientVersion(version string) {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version = version
+}
+
+// Ping reports the API version the fake Docker host speaks
+func (c *Client) Ping(ctx context.Context) (types.Ping, error) {
+
+	if err := c.failure("Ping"); err != nil {
+		return types.Ping{}, err
+	}
+	return types.Ping{APIVersion: c.apiVersion}, nil
+}
+
+// ImageBuild reads the build context and tags a new image with options.Tags, or fails with BuildError
+func (c *Client) ImageBuild(ctx context.Context, buildContext io.Reader, options types.ImageBuildOptions) (types.ImageBuildResponse, error) {
+
+	if err := c.failure("ImageBuild"); err != nil {
+		return types.ImageBuildResponse{}, err
+	}
+	if buildContext != nil {
+		if _, err := io.Copy(ioutil.Discard, buildContext); err != nil {
+			return types.ImageBuildResponse{}, err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.builds = append(c.builds, options)
+
+	step := map[string]string{"stream": "Step 1/2 : FROM scratch\n"}
+	if c.BuildError != "" {
+		return types.ImageBuildResponse{Body: jsonStream(step, streamError(c.BuildError))}, nil
+	}
+	id := c.addImage(options.Tags, &container.Config{Labels: options.Labels})
+	aux := map[string]interface{}{"aux": map[string]string{"ID": id}}
+	built := map[string]string{"stream": "Successfully built " + strings.TrimPrefix(id, "sha256:")[:12] + "\n"}
+	return types.ImageBuildResponse{Body: jsonStream(step, aux, built)}, nil
+}
+
+// ImagePush puts a local image in the registry and reports its digest, or fails with PushError
+func (c *Client) ImagePush(ctx context.Context, ref string, options types.ImagePushOptions) (io.ReadCloser, error) {
+
+	if err := c.failure("ImagePush"); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pushes = append(c.pushes, ref)
+
+	repo := repository(ref)
+	start := map[string]string{"status": "The push refers to a repository [" + repo + "]"}
+	image, ok := c.images[c.tags[normalize(ref)]]
+	if !ok {
+		return jsonStream(start, streamError("An image does not exist locally with the tag: "+repo)), nil
+	}
+	layer := map[string]interface{}{"status": "Pushed", "progressDetail": map[string]string{}, "id": strings.TrimPrefix(image.ID, "sha256:")[:12]}
+	if c.PushError != "" {
+		return jsonStream(start, layer, streamError(c.PushError)), nil
+	}
+
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(image.ID+repo)))
+	image.RepoDigests = append(without(image.RepoDigests, repo+"@"+digest), repo+"@"+digest)
+	c.registry[normalize(ref)] = image.ID
+	pushed := map[string]string{"status": tag(ref) + ": digest: " + digest + " size: 528"}
+	aux := map[string]interface{}{"progressDetail": map[string]string{}, "aux": map[string]interface{}{"Tag": tag(ref), "Digest": digest, "Size": 528}}
+	return jsonStream(start, layer, pushed, aux), nil
+}
+
+// ImagePull tags the image the registry has for ref locally
+func (c *Client) ImagePull(ctx context.Context, ref string, options types.ImagePullOptions) (io.ReadCloser, error) {
+
+	if err := c.failure("ImagePull"); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id, ok := c.registry[normalize(ref)]
+	if !ok {
+		return nil, notFoundError{"manifest for " + normalize(ref) + " not found"}
+	}
+	status := "Status: Image is up to date for " + normalize(ref)
+	if c.tags[normalize(ref)] != id {
+		status = "Status: Downloaded newer image for " + normalize(ref)
+		c.tag(ref, id)
+	}
+	return jsonStream(map[string]string{"status": status}), nil
+}
+
+// ImageInspectWithRaw finds a local image by reference or ID
+func (c *Client) ImageInspectWithRaw(ctx context.Context, imageID string) (types.ImageInspect, []byte, error) {
+
+	if err := c.failure("ImageInspectWithRaw"); err != nil {
+		return types.ImageInspect{}, nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	image, ok := c.image(imageID)
+	if !ok {
+		return types.ImageInspect{}, nil, notFoundError{"No such image: " + imageID}
+	}
+	raw, _ := json.Marshal(image)
+	return *image, raw, nil
+}
+
+// ContainerCreate creates a container from a local image, which passes its labels on to the container
+func (c *Client) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, containerName string) (container.ContainerCreateCreatedBody, error) {
+
+	if err := c.failure("ContainerCreate"); err != nil {
+		return container.ContainerCreateCreatedBody{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	image, ok := c.image(config.Image)
+	if !ok {
+		return container.ContainerCreateCreatedBody{}, notFoundError{"No such image: " + config.Image}
+	}
+	if _, taken := c.container(containerName); containerName != "" && taken {
+		return container.ContainerCreateCreatedBody{}, errors.New(`Conflict. The container name "/` + containerName + `" is already in use`)
+	}
+
+	merged := *config
+	merged.Labels = map[string]string{}
+	if image.Config != nil {
+		for name, value := range image.Config.Labels {
+			merged.Labels[name] = value
+		}
+	}
+	for name, value := range config.Labels {
+		merged.Labels[name] = value
+	}
+
+	ctr := &Container{ID: c.newID(), Name: containerName, Config: &merged, HostConfig: hostConfig, exited: make(chan struct{})}
+	if hostConfig != nil && hostConfig.NetworkMode != "" {
+		ctr.Networks = append(ctr.Networks, string(hostConfig.NetworkMode))
+	}
+	if networkingConfig != nil {
+		for name := range networkingConfig.EndpointsConfig {
+			if len(ctr.Networks) == 0 || ctr.Networks[0] != name {
+				ctr.Networks = append(ctr.Networks, name)
+			}
+		}
+	}
+	c.containers[ctr.ID] = ctr
+	return container.ContainerCreateCreatedBody{ID: ctr.ID}, nil
+}
+
+// ContainerStart starts a container, which does what Run says
+func (c *Client) ContainerStart(ctx context.Context, containerID string, options types.ContainerStartOptions) error {
+
+	if err := c.failure("ContainerStart"); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	ctr, ok := c.container(containerID)
+	if !ok {
+		c.mu.Unlock()
+		return notFoundError{"No such container: " + containerID}
+	}
+	if ctr.Started {
+		c.mu.Unlock()
+		return nil
+	}
+	run := c.Run
+	c.mu.Unlock()
+
+	result := Result{Running: true}
+	if run != nil {
+		result = run(ctr)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ctr.Started = true
+	ctr.Result = result
+	ctr.startedAt = time.Now()
+	if !result.Running {
+		ctr.finishedAt = time.Now()
+		close(ctr.exited)
+	}
+	return nil
+}
+
+// ContainerWait blocks until the container exits or is removed and returns its exit code
+func (c *Client) ContainerWait(ctx context.Context, containerID string) (int64, error) {
+
+	if err := c.failure("ContainerWait"); err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	ctr, ok := c.container(containerID)
+	c.mu.Unlock()
+	if !ok {
+		return 0, notFoundError{"No such container: " + containerID}
+	}
+
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-ctr.exited:
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return int64(ctr.Result.ExitCode), nil
+}
+
+// ContainerInspect describes a container the way the Docker host does
+func (c *Client) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+
+	if err := c.failure("ContainerInspect"); err != nil {
+		return types.ContainerJSON{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ctr, ok := c.container(containerID)
+	if !ok {
+		return types.ContainerJSON{}, notFoundError{"No such container: " + containerID}
+	}
+
+	state := &types.ContainerState{
+		Status:     ctr.state(),
+		Running:    ctr.running(),
+		StartedAt:  ctr.startedAt.UTC().Format(time.RFC3339Nano),
+		FinishedAt: ctr.finishedAt.UTC().Format(time.RFC3339Nano),
+	}
+	if ctr.Started && !ctr.running() {
+		state.ExitCode = ctr.Result.ExitCode
+		state.OOMKilled = ctr.Result.OOMKilled
+	}
+	settings := &types.NetworkSettings{}
+	if ctr.running() {
+		settings.Gateway = "172.17.0.1"
+		settings.IPAddress = "172.17.0.2"
+	}
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{
+			ID:         ctr.ID,
+			Name:       "/" + ctr.Name,
+			Image:      ctr.Config.Image,
+			State:      state,
+			HostConfig: ctr.HostConfig,
+		},
+		Config:          ctr.Config,
+		NetworkSettings: settings,
+	}, nil
+}
+
+// ContainerLogs returns what the container wrote, multiplexed like the Docker host does
+func (c *Client) ContainerLogs(ctx context.Context, containerID string, options types.ContainerLogsOptions) (io.ReadCloser, error) {
+
+	if err := c.failure("ContainerLogs"); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ctr, ok := c.container(containerID)
+	if !ok {
+		return nil, notFoundError{"No such container: " + containerID}
+	}
+	var stdout, stderr string
+	if options.ShowStdout {
+		stdout = ctr.Result.Stdout
+	}
+	if options.ShowStderr {
+		stderr = ctr.Result.Stderr
+	}
+	return ioutil.NopCloser(bytes.NewReader(multiplexed(stdout, stderr))), nil
+}
+
+// ContainerRemove removes a container, a running one only with options.Force
+func (c *Client) ContainerRemove(ctx context.Context, containerID string, options types.ContainerRemoveOptions) error {
+
+	if err := c.failure("ContainerRemove"); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ctr, ok := c.container(containerID)
+	if !ok {
+		return notFoundError{"No such container: " + containerID}
+	}
+	if ctr.running() {
+		if !options.Force {
+			return errors.New("Conflict, You cannot remove a running container " + ctr.ID + ". Stop the container before attempting removal or use -f")
+		}
+		// killed like docker rm -f does
+		ctr.Result.ExitCode = 137
+		ctr.finishedAt = time.Now()
+		close(ctr.exited)
+	}
+	delete(c.containers, ctr.ID)
+	return nil
+}
+
+// ContainerList lists the running containers, or all with options.All, that match the label filters
+func (c *Client) ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
+
+	if err := c.failure("ContainerList"); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	labels := options.Filters.Get("label")
+	var list []types.Container
+	for _, id := range sortedKeys(c.containers) {
+		ctr := c.containers[id]
+		if !options.All && !ctr.running() {
+			continue
+		}
+		if !hasLabels(ctr.Config.Labels, labels) {
+			continue
+		}
+		list = append(list, types.Container{
+			ID:     ctr.ID,
+			Names:  []string{"/" + ctr.Name},
+			Image:  ctr.Config.Image,
+			Labels: ctr.Config.Labels,
+			State:  ctr.state(),
+		})
+	}
+	return list, nil
+}
+
+func sortedKeys(containers map[string]*Container) []string {
+
+	keys := make([]string, 0, len(containers))
+	for id := range containers {
+		keys = append(keys, id)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// hasLabels reports whether labels match every label filter, NAME or NAME=VALUE
+func hasLabels(labels map[string]string, filters []string) bool {
+
+	for _, filter := range filters {
+		parts := strings.SplitN(filter, "=", 2)
+		value, ok := labels[parts[0]]
+		if !ok || (len(parts) == 2 && value != parts[1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// NetworkConnect connects a container to another network
+func (c *Client) NetworkConnect(ctx context.Context, networkID, containerID string, config *network.EndpointSettings) error {
+
+	if err := c.failure("NetworkConnect"); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ctr, ok := c.container(containerID)
+	if !ok {
+		return notFoundError{"No such container: " + containerID}
+	}
+	ctr.Networks = append(ctr.Networks, networkID)
+	return nil
+}
+
+// ContainerExecCreate sets up a command to run in a running container
+func (c *Client) ContainerExecCreate(ctx context.Context, containerID string, config types.ExecConfig) (types.IDResponse, error) {
+
+	if err := c.failure("ContainerExecCreate"); err != nil {
+		return types.IDResponse{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ctr, ok := c.container(containerID)
+	if !ok {
+		return types.IDResponse{}, notFoundError{"No such container: " + containerID}
+	}
+	if !ctr.running() {
+		return types.IDResponse{}, errors.New("Container " + ctr.ID + " is not running")
+	}
+	id := c.newID()
+	c.execs[id] = &execInstance{containerID: ctr.ID, config: config}
+	return types.IDResponse{ID: id}, nil
+}
+
+// ContainerExecAttach runs the command, which does what Exec says, and returns its multiplexed output
+func (c *Client) ContainerExecAttach(ctx context.Context, execID string, config types.ExecConfig) (types.HijackedResponse, error) {
+
+	if err := c.failure("ContainerExecAttach"); err != nil {
+		return types.HijackedResponse{}, err
+	}
+
+	c.mu.Lock()
+	instance, ok := c.execs[execID]
+	if !ok {
+		c.mu.Unlock()
+		return types.HijackedResponse{}, notFoundError{"No such exec instance: " + execID}
+	}
+	ctr, exists := c.container(instance.containerID)
+	running := exists && ctr.running()
+	exec := c.Exec
+	c.mu.Unlock()
+	if !running {
+		return types.HijackedResponse{}, errors.New("Container " + instance.containerID + " is not running")
+	}
+
+	result := Result{}
+	if exec != nil {
+		result = exec(ctr, instance.config.Cmd)
+	}
+	c.mu.Lock()
+	instance.result = &result
+	c.mu.Unlock()
+
+	conn, other := net.Pipe()
+	other.Close()
+	return types.HijackedResponse{Conn: conn, Reader: bufio.NewReader(bytes.NewReader(multiplexed(result.Stdout, result.Stderr)))}, nil
+}
+
+// ContainerExecInspect reports whether a command is still running and its exit code
+func (c *Client) ContainerExecInspect(ctx context.Context, execID string) (types.ContainerExecInspect, error) {
+
+	if err := c.failure("ContainerExecInspect"); err != nil {
+		return types.ContainerExecInspect{}, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	instance, ok := c.execs[execID]
+	if !ok {
+		return types.ContainerExecInspect{}, notFoundError{"No such exec instance: " + execID}
+	}
+	inspect := types.ContainerExecInspect{ExecID: execID, ContainerID: instance.containerID, Running: instance.result == nil}
+	if instance.result != nil {
+		inspect.ExitCode = instance.result.ExitCode
+	}
+	return inspect, nil
+}
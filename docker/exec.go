@@ -0,0 +1,82 @@
+package docker
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+	"golang.org/x/net/context"
+)
+
+// Exec runs cmd inside the running container and returns its output and exit code. env adds
+// KEY=VALUE variables to the container's environment, user (name or uid[:gid]) and workdir default
+// to the container's own when empty. It stops waiting for the command when ctx is done.
+func (d *Host) Exec(ctx context.Context, containerID string, cmd, env []string, user, workdir string) (*CommandResult, error) {
+
+	if len(cmd) == 0 {
+		return nil, errors.New("no command to run")
+	}
+	if workdir != "" {
+		// the exec API this client speaks has no working directory, so the shell changes to it
+		cmd = append([]string{"sh", "-c", `cd "$0" && exec "$@"`, workdir}, cmd...)
+	}
+
+	config := types.ExecConfig{
+		User:         user,
+		Env:          env,
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	}
+	started := time.Now()
+	exec, err := d.DockerCli.ContainerExecCreate(ctx, containerID, config)
+	if err != nil {
+		return nil, err
+	}
+	attached, err := d.DockerCli.ContainerExecAttach(ctx, exec.ID, config)
+	if err != nil {
+		return nil, err
+	}
+	defer attached.Close()
+
+	// reading the output only ends with the command, unless the connection is closed
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			attached.Close()
+		case <-done:
+		}
+	}()
+
+	var stdout, stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, attached.Reader); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	// the output ends when the process does, but its exit code may take a moment to show up
+	for {
+		inspect, err := d.DockerCli.ContainerExecInspect(ctx, exec.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !inspect.Running {
+			return &CommandResult{
+				Stdout:     stdout.String(),
+				Stderr:     stderr.String(),
+				WaitResult: WaitResult{StatusCode: inspect.ExitCode, Duration: time.Since(started)},
+			}, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// JSONMessage is one message of the JSON stream the Docker host sends back while building,
+// pushing or pulling an image
+type JSONMessage struct {
+	Stream      string           `json:"stream,omitempty"`
+	Status      string           `json:"status,omitempty"`
+	Progress    string           `json:"progress,omitempty"`
+	ID          string           `json:"id,omitempty"`
+	Error       string           `json:"error,omitempty"`
+	ErrorDetail *JSONError       `json:"errorDetail,omitempty"`
+	Aux         *json.RawMessage `json:"aux,omitempty"`
+}
+
+// JSONError is the errorDetail of a JSONMessage
+type JSONError struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// ErrorMessage returns the error carried by the message, or "" if there is none
+func (m JSONMessage) ErrorMessage() string {
+
+	if m.ErrorDetail != nil && m.ErrorDetail.Message != "" {
+		return m.ErrorDetail.Message
+	}
+	return m.Error
+}
+
+// ProgressFunc receives every message decoded from a build, push or pull stream
+type ProgressFunc func(JSONMessage)
+
+// decodeStream calls fn for each message in r until r is exhausted or fn returns an error
+func decodeStream(r io.Reader, fn func(JSONMessage) error) error {
+
+	decoder := json.NewDecoder(r)
+	for {
+		var m JSONMessage
+		if err := decoder.Decode(&m); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+}
+
+// TerminalProgress returns a ProgressFunc that prints build output as is and layer
+// progress one line per change of status, which keeps logs readable without a tty
+func TerminalProgress(w io.Writer) ProgressFunc {
+
+	lastStatus := map[string]string{}
+	return func(m JSONMessage) {
+		switch {
+		case m.ErrorMessage() != "":
+			fmt.Fprintln(w, "ERROR:", m.ErrorMessage())
+		case m.Stream != "":
+			fmt.Fprint(w, m.Stream)
+		case m.Status != "" && m.ID != "":
+			if lastStatus[m.ID] == m.Status {
+				return
+			}
+			lastStatus[m.ID] = m.Status
+			fmt.Fprintf(w, "%s: %s\n", m.ID, m.Status)
+		case m.Status != "":
+			fmt.Fprintln(w, strings.TrimSpace(m.Status))
+		}
+	}
+}
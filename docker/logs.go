@@ -0,0 +1,26 @@
+package docker
+
+import (
+	"bytes"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+	"golang.org/x/net/context"
+)
+
+// ContainerLogs returns everything the container wrote so far, split into stdout and stderr.
+// It only works for containers created without a TTY, which is how the Host creates them.
+func (d *Host) ContainerLogs(ctx context.Context, containerID string) (stdout, stderr []byte, err error) {
+
+	logs, err := d.DockerCli.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{ShowStdout: true, ShowStderr: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer logs.Close()
+
+	var out, errOut bytes.Buffer
+	if _, err := stdcopy.StdCopy(&out, &errOut, logs); err != nil {
+		return nil, nil, err
+	}
+	return out.Bytes(), errOut.Bytes(), nil
+}
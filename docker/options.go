@@ -0,0 +1,137 @@
+package docker
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/go-connections/sockets"
+	"github.com/docker/go-connections/tlsconfig"
+)
+
+// DefaultHost is the local Docker daemon, used when neither Options nor DOCKER_HOST name a host
+const DefaultHost = "unix:///var/run/docker.sock"
+
+// Options says how to reach the Docker host. Settings left empty are taken from the
+// DOCKER_HOST, DOCKER_TLS_VERIFY and DOCKER_CERT_PATH environment variables, like the docker CLI does.
+type Options struct {
+	// Host is tcp://<address>:<port>, unix:///path/to/docker.sock or an http(s) URL
+	Host string
+	// TLSFolder holds cert.pem, key.pem and ca.pem
+	TLSFolder string
+	// CertFile, KeyFile and CAFile override the files in TLSFolder
+	CertFile string
+	KeyFile  string
+	CAFile   string
+	// TLSVerify turns on TLS and checks the daemon's certificate, as DOCKER_TLS_VERIFY does
+	TLSVerify bool
+	// InsecureSkipVerify uses TLS without checking the daemon's certificate: please know what you are doing!
+	InsecureSkipVerify bool
+	// UserAgent identifies dockhand to the Docker host, DefaultUserAgent when empty
+	UserAgent string
+}
+
+// DefaultUserAgent is sent when Options has no UserAgent
+const DefaultUserAgent = "dockhand"
+
+// withEnv fills in what the options leave empty from the environment and the defaults
+func (o Options) withEnv() Options {
+
+	if o.Host == "" {
+		o.Host = os.Getenv("DOCKER_HOST")
+	}
+	if o.Host == "" {
+		o.Host = DefaultHost
+	}
+	o.Host = strings.TrimSuffix(o.Host, "/")
+
+	if os.Getenv("DOCKER_TLS_VERIFY") != "" {
+		o.TLSVerify = true
+	}
+	if o.TLSFolder == "" {
+		o.TLSFolder = os.Getenv("DOCKER_CERT_PATH")
+	}
+	if o.TLSFolder == "" && o.TLSVerify {
+		if home := os.Getenv("HOME"); home != "" {
+			o.TLSFolder = filepath.Join(home, ".docker")
+		}
+	}
+
+	if o.TLSFolder != "" {
+		if o.CertFile == "" {
+			o.CertFile = filepath.Join(o.TLSFolder, "cert.pem")
+		}
+		if o.KeyFile == "" {
+			o.KeyFile = filepath.Join(o.TLSFolder, "key.pem")
+		}
+		if o.CAFile == "" {
+			o.CAFile = filepath.Join(o.TLSFolder, "ca.pem")
+		}
+	}
+	return o
+}
+
+// useTLS reports whether the options ask for TLS, which is never used over a unix socket
+func (o Options) useTLS(scheme string) bool {
+
+	switch scheme {
+	case "unix", "npipe":
+		return false
+	case "https":
+		return true
+	}
+	return o.TLSVerify || o.InsecureSkipVerify || o.CertFile != "" || o.KeyFile != "" || o.CAFile != ""
+}
+
+// transport picks how to talk to the host: over its unix socket, TCP with TLS or plain TCP
+func (o Options) transport() (*http.Transport, error) {
+
+	host, err := url.Parse(o.Host)
+	if err != nil {
+		return nil, errors.New("invalid Docker host " + o.Host + ": " + err.Error())
+	}
+
+	transport := &http.Transport{}
+	switch host.Scheme {
+	case "unix":
+		if err := sockets.ConfigureTransport(transport, "unix", host.Path); err != nil {
+			return nil, err
+		}
+	case "tcp", "http", "https":
+	default:
+		return nil, errors.New("invalid Docker host " + o.Host + ": scheme must be tcp, unix, http or https")
+	}
+
+	if o.useTLS(host.Scheme) {
+		tlsConfig, err := tlsconfig.Client(tlsconfig.Options{
+			CAFile:             o.CAFile,
+			CertFile:           o.CertFile,
+			KeyFile:            o.KeyFile,
+			InsecureSkipVerify: o.InsecureSkipVerify,
+		})
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	return transport, nil
+}
+
+// New creates a Docker Host from options, falling back on the DOCKER_* environment variables and
+// the local daemon's unix socket, and picks the transport the host needs
+func New(options Options) (*Host, error) {
+
+	options = options.withEnv()
+	transport, err := options.transport()
+	if err != nil {
+		return nil, err
+	}
+	userAgent := options.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	return newClientFromTransport(options.Host, transport, userAgent)
+}
@@ -0,0 +1,152 @@
+package docker
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setEnv sets the DOCKER_* variables for a test and returns a func restoring them
+func setEnv(values map[string]string) func() {
+
+	saved := map[string]string{}
+	for _, name := range []string{"DOCKER_HOST", "DOCKER_TLS_VERIFY", "DOCKER_CERT_PATH", "HOME"} {
+		saved[name] = os.Getenv(name)
+		os.Setenv(name, values[name])
+	}
+	return func() {
+		for name, value := range saved {
+			os.Setenv(name, value)
+		}
+	}
+}
+
+func TestOptionsWithEnv(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		options Options
+		env     map[string]string
+		want    Options
+	}{
+		{
+			name: "local daemon",
+			want: Options{Host: DefaultHost},
+		},
+		{
+			name: "DOCKER_HOST",
+			env:  map[string]string{"DOCKER_HOST": "tcp://docker.example.com:2375"},
+			want: Options{Host: "tcp://docker.example.com:2375"},
+		},
+		{
+			name:    "options win",
+			options: Options{Host: "tcp://build.example.com:2376/"},
+			env:     map[string]string{"DOCKER_HOST": "tcp://docker.example.com:2375"},
+			want:    Options{Host: "tcp://build.example.com:2376"},
+		},
+		{
+			name: "DOCKER_TLS_VERIFY uses ~/.docker",
+			env:  map[string]string{"DOCKER_HOST": "tcp://docker.example.com:2376", "DOCKER_TLS_VERIFY": "1", "HOME": "/home/me"},
+			want: Options{
+				Host:      "tcp://docker.example.com:2376",
+				TLSVerify: true,
+				TLSFolder: "/home/me/.docker",
+				CertFile:  "/home/me/.docker/cert.pem",
+				KeyFile:   "/home/me/.docker/key.pem",
+				CAFile:    "/home/me/.docker/ca.pem",
+			},
+		},
+		{
+			name:    "single files override the folder",
+			options: Options{Host: "tcp://docker.example.com:2376", CAFile: "/etc/docker/ca.pem"},
+			env:     map[string]string{"DOCKER_CERT_PATH": "/certs"},
+			want: Options{
+				Host:      "tcp://docker.example.com:2376",
+				TLSFolder: "/certs",
+				CertFile:  "/certs/cert.pem",
+				KeyFile:   "/certs/key.pem",
+				CAFile:    "/etc/docker/ca.pem",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		restore := setEnv(test.env)
+		got := test.options.withEnv()
+		restore()
+		if got != test.want {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestOptionsTransport(t *testing.T) {
+
+	tests := []struct {
+		options Options
+		tls     bool
+		fails   bool
+	}{
+		{options: Options{Host: "tcp://localhost:2375"}},
+		{options: Options{Host: "tcp://localhost:2376", TLSVerify: true}, tls: true},
+		{options: Options{Host: "tcp://localhost:2376", InsecureSkipVerify: true}, tls: true},
+		{options: Options{Host: "https://localhost:2376"}, tls: true},
+		{options: Options{Host: "unix:///var/run/docker.sock", TLSVerify: true}},
+		{options: Options{Host: "tcp://localhost:2376", CertFile: "/no/such/cert.pem", KeyFile: "/no/such/key.pem"}, fails: true},
+		{options: Options{Host: "ssh://localhost"}, fails: true},
+	}
+
+	for _, test := range tests {
+		transport, err := test.options.transport()
+		if test.fails {
+			if err == nil {
+				t.Errorf("%+v: expected an error", test.options)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%+v: unexpected error %v", test.options, err)
+			continue
+		}
+		if (transport.TLSClientConfig != nil) != test.tls {
+			t.Errorf("%+v: TLS %v, want %v", test.options, transport.TLSClientConfig != nil, test.tls)
+		}
+	}
+}
+
+func TestUnixSocketTransport(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "dockhand")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "docker.sock")
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skip("cannot listen on a unix socket:", err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})}
+	go server.Serve(listener)
+	defer listener.Close()
+
+	transport, err := Options{Host: "unix://" + socket}.transport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: transport}).Get("http://docker/_ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != "OK" {
+		t.Errorf("ping answered %q", body)
+	}
+}
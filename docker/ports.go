@@ -0,0 +1,43 @@
+package docker
+
+import (
+	"errors"
+	"net"
+	"net/url"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/go-connections/nat"
+)
+
+// PublishedAddress returns the host:port dockhand reaches port (e.g. 22/tcp) of the container on,
+// which must have been published with ContainerSpec.Ports: the address of a remote Docker host,
+// the loopback address when the Docker host is local
+func (d *Host) PublishedAddress(info types.ContainerJSON, port string) (string, error) {
+
+	var bindings []nat.PortBinding
+	if info.NetworkSettings != nil {
+		bindings = info.NetworkSettings.Ports[nat.Port(port)]
+	}
+	if len(bindings) == 0 || bindings[0].HostPort == "" {
+		return "", errors.New("port " + port + " of the container is not published on the Docker host")
+	}
+	host := bindings[0].HostIP
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = d.address()
+	}
+	return net.JoinHostPort(host, bindings[0].HostPort), nil
+}
+
+// address is the name or IP of the Docker host in URL, the loopback address for a local socket
+func (d *Host) address() string {
+
+	u, err := url.Parse(d.URL)
+	if err != nil || u.Host == "" || u.Scheme == "unix" || u.Scheme == "npipe" {
+		return "127.0.0.1"
+	}
+	host, _, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return u.Host
+	}
+	return host
+}
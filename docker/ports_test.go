@@ -0,0 +1,39 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/go-connections/nat"
+)
+
+func TestPublishedAddress(t *testing.T) {
+
+	published := func(hostIP string) types.ContainerJSON {
+		settings := &types.NetworkSettings{}
+		settings.Ports = nat.PortMap{"22/tcp": {{HostIP: hostIP, HostPort: "32768"}}}
+		return types.ContainerJSON{NetworkSettings: settings}
+	}
+
+	tests := []struct {
+		url  string
+		info types.ContainerJSON
+		want string
+	}{
+		{"tcp://docker.example.com:2376", published("0.0.0.0"), "docker.example.com:32768"},
+		{"https://10.0.0.5:2376", published(""), "10.0.0.5:32768"},
+		{"tcp://docker.example.com", published("0.0.0.0"), "docker.example.com:32768"},
+		{"unix:///var/run/docker.sock", published("0.0.0.0"), "127.0.0.1:32768"},
+		{"tcp://docker.example.com:2376", published("10.0.0.7"), "10.0.0.7:32768"},
+	}
+	for _, test := range tests {
+		got, err := NewWithClient(test.url, nil).PublishedAddress(test.info, "22/tcp")
+		if err != nil || got != test.want {
+			t.Errorf("%s: got %q, %v, want %q", test.url, got, err, test.want)
+		}
+	}
+
+	if _, err := NewWithClient("tcp://docker.example.com:2376", nil).PublishedAddress(types.ContainerJSON{}, "22/tcp"); err == nil {
+		t.Error("unpublished port: expected an error")
+	}
+}
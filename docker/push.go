@@ -0,0 +1,88 @@
+package docker
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// PushError is returned when the registry or Docker host rejects a push
+type PushError struct {
+	Image string
+	// Layer is the ID of the layer being pushed when the error came, if any
+	Layer   string
+	Message string
+}
+
+func (e *PushError) Error() string {
+
+	if e.Layer == "" {
+		return "push of " + e.Image + " failed: " + e.Message
+	}
+	return "push of " + e.Image + " failed at layer " + e.Layer + ": " + e.Message
+}
+
+var digestStatus = regexp.MustCompile(`digest: (sha256:[0-9a-f]{64})`)
+
+// readPushOutput consumes the push output stream, passing every message on to progress,
+// and returns the digest of the manifest the registry stored or a *PushError
+func readPushOutput(imageName string, r io.Reader, progress ProgressFunc) (string, error) {
+
+	var digest, layer string
+	var pushErr *PushError
+
+	err := decodeStream(r, func(m JSONMessage) error {
+		if progress != nil {
+			progress(m)
+		}
+
+		if msg := m.ErrorMessage(); msg != "" {
+			pushErr = &PushError{Image: imageName, Layer: layer, Message: strings.TrimSpace(msg)}
+			return nil
+		}
+		if m.ID != "" && m.Status != "" {
+			layer = m.ID
+		}
+
+		if m.Aux != nil {
+			var aux struct{ Digest string }
+			if json.Unmarshal(*m.Aux, &aux) == nil && aux.Digest != "" {
+				digest = aux.Digest
+			}
+		}
+		if match := digestStatus.FindStringSubmatch(m.Status); match != nil && digest == "" {
+			digest = match[1]
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if pushErr != nil {
+		return "", pushErr
+	}
+	if digest == "" {
+		return "", errors.New("push of " + imageName + " finished without the registry reporting a digest")
+	}
+	return digest, nil
+}
+
+// repository returns imageName without its tag or digest
+func repository(imageName string) string {
+
+	if i := strings.Index(imageName, "@"); i >= 0 {
+		imageName = imageName[:i]
+	}
+	// a colon after the last slash starts the tag, one before it is a registry port
+	if i := strings.LastIndex(imageName, ":"); i > strings.LastIndex(imageName, "/") {
+		imageName = imageName[:i]
+	}
+	return imageName
+}
+
+// DigestReference returns the immutable repository@sha256:... reference of a pushed image
+func DigestReference(imageName, digest string) string {
+	return repository(imageName) + "@" + digest
+}
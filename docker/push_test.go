@@ -0,0 +1,85 @@
+package docker
+
+import (
+	"strings"
+	"testing"
+)
+
+const testDigest = "sha256:4a5573037f358b6cdfa2f3e8a9c33a5cf11bcd1675ca72ca76fbe5bd77d0d682"
+
+func TestReadPushOutput(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		stream  string
+		want    string
+		wantErr string
+		layer   string
+	}{
+		{
+			name: "aux digest",
+			stream: `{"status":"The push refers to a repository [registry.example.com/slave]"}
+{"status":"Preparing","progressDetail":{},"id":"a1b2c3"}
+{"status":"Pushing","progressDetail":{"current":512,"total":1024},"progress":"[=>  ]","id":"a1b2c3"}
+{"status":"Pushed","progressDetail":{},"id":"a1b2c3"}
+{"status":"latest: digest: ` + testDigest + ` size: 1234"}
+{"progressDetail":{},"aux":{"Tag":"latest","Digest":"` + testDigest + `","Size":1234}}`,
+			want: testDigest,
+		},
+		{
+			name:   "status digest only",
+			stream: `{"status":"latest: digest: ` + testDigest + ` size: 1234"}`,
+			want:   testDigest,
+		},
+		{
+			name: "layer error",
+			stream: `{"status":"Preparing","id":"a1b2c3"}
+{"status":"Pushing","id":"a1b2c3"}
+{"errorDetail":{"message":"unauthorized: authentication required"},"error":"unauthorized: authentication required"}`,
+			wantErr: "unauthorized",
+			layer:   "a1b2c3",
+		},
+		{
+			name:    "no digest",
+			stream:  `{"status":"Layer already exists","id":"a1b2c3"}`,
+			wantErr: "without the registry reporting a digest",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			digest, err := readPushOutput("registry.example.com/slave", strings.NewReader(tt.stream), nil)
+			if tt.wantErr == "" {
+				if err != nil || digest != tt.want {
+					t.Fatalf("got %q, %v; want %q", digest, err, tt.want)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+			if tt.layer != "" {
+				pushErr, ok := err.(*PushError)
+				if !ok || pushErr.Layer != tt.layer {
+					t.Errorf("got %#v, want a *PushError for layer %s", err, tt.layer)
+				}
+			}
+		})
+	}
+}
+
+func TestDigestReference(t *testing.T) {
+
+	tests := []struct{ image, want string }{
+		{"jenkins-slave", "jenkins-slave@" + testDigest},
+		{"jenkins-slave:2.1", "jenkins-slave@" + testDigest},
+		{"registry.example.com:5000/team/slave", "registry.example.com:5000/team/slave@" + testDigest},
+		{"registry.example.com:5000/team/slave:latest", "registry.example.com:5000/team/slave@" + testDigest},
+		{"team/slave@sha256:0000", "team/slave@" + testDigest},
+	}
+	for _, tt := range tests {
+		if got := DigestReference(tt.image, testDigest); got != tt.want {
+			t.Errorf("DigestReference(%q) = %q, want %q", tt.image, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package docker
+
+import (
+	"errors"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/strslice"
+	"golang.org/x/net/context"
+)
+
+// CommandResult is the output and exit status of a command run in a container
+type CommandResult struct {
+	Stdout string
+	Stderr string
+	WaitResult
+}
+
+// RunCommand runs cmd in a new container from imageName, waits for it to finish and removes it again.
+// cmd replaces the entrypoint of the image; env, user and workdir work like they do for Exec.
+// A command still running after timeout (no limit when 0) is killed and a *WaitTimeoutError returned.
+func (d *Host) RunCommand(ctx context.Context, imageName string, cmd, env []string, user, workdir string, timeout time.Duration) (*CommandResult, error) {
+
+	if len(cmd) == 0 {
+		return nil, errors.New("no command to run")
+	}
+	containerID, err := d.startContainer(ctx, &container.Config{
+		Image:      imageName,
+		Entrypoint: strslice.StrSlice(cmd[:1]),
+		Cmd:        strslice.StrSlice(cmd[1:]),
+		Env:        env,
+		User:       user,
+		WorkingDir: workdir,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer d.removeDetached(containerID)
+
+	waitResult, err := d.WaitContainer(ctx, containerID, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, stderr, err := d.ContainerLogs(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	return &CommandResult{Stdout: string(stdout), Stderr: string(stderr), WaitResult: waitResult}, nil
+}
+
+// StartCommand starts cmd in a new container from imageName in the background and returns the
+// container ID. cmd replaces the entrypoint of the image; the caller removes the container.
+func (d *Host) StartCommand(ctx context.Context, imageName string, cmd []string) (string, error) {
+
+	if len(cmd) == 0 {
+		return "", errors.New("no command to run")
+	}
+
+	return d.startContainer(ctx, &container.Config{
+		Image:      imageName,
+		Entrypoint: strslice.StrSlice(cmd[:1]),
+		Cmd:        strslice.StrSlice(cmd[1:]),
+	})
+}
+
+// startContainer creates and starts an unnamed container
+func (d *Host) startContainer(ctx context.Context, config *container.Config) (string, error) {
+
+	created, err := d.createContainer(ctx, config, nil, nil, "")
+	if err != nil {
+		return "", err
+	}
+	if err := d.StartContainer(ctx, created.ID); err != nil {
+		d.removeDetached(created.ID)
+		return "", err
+	}
+	return created.ID, nil
+}
+
+// removeTimeout is how long removing a container may take when the operation that created it is done
+const removeTimeout = time.Minute
+
+// removeDetached removes a container that is no longer needed, even when the context of the
+// operation that created it has already timed out or been cancelled
+func (d *Host) removeDetached(containerID string) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), removeTimeout)
+	defer cancel()
+	return d.ContainerRemove(ctx, containerID)
+}
@@ -0,0 +1,121 @@
+package docker
+
+import (
+	"errors"
+
+	"github.com/docker/docker/api/types/versions"
+	"golang.org/x/net/context"
+)
+
+// API versions dockhand speaks: MaxAPIVersion is the newest its Docker client knows,
+// MinAPIVersion the oldest it has been used against
+const (
+	MaxAPIVersion = "1.26"
+	MinAPIVersion = "1.24"
+)
+
+// unreportedAPIVersion is assumed for daemons older than Docker 1.13, which do not report their API version
+const unreportedAPIVersion = "1.24"
+
+// Features that need a newer API than MinAPIVersion
+const (
+	FeatureBuildTarget = "multi-stage build targets"
+)
+
+// sentByDockhand are the features dockhand adds to its requests itself, past its Docker client,
+// so they only need the Docker host to speak a new enough API
+var sentByDockhand = map[string]bool{
+	FeatureBuildTarget: true,
+}
+
+// featureAPIVersions is the API version each feature first appeared in
+var featureAPIVersions = map[string]string{
+	FeatureBuildTarget: "1.29",
+}
+
+// APIVersionError is returned when a feature needs a newer API than the connection to the Docker host uses
+type APIVersionError struct {
+	Feature string
+	// Required is the API version the feature needs
+	Required string
+	// Version is the API version in use and ServerVersion the newest the Docker host speaks
+	Version       string
+	ServerVersion string
+}
+
+func (e *APIVersionError) Error() string {
+
+	msg := e.Feature + " need Docker API " + e.Required + ", dockhand talks to the Docker host with API " + e.Version
+	switch {
+	case e.ServerVersion == "":
+		return msg
+	case e.ServerVersion == e.Version:
+		return e.Feature + " need Docker API " + e.Required + ", the Docker host only speaks " + e.ServerVersion
+	case versions.LessThan(e.ServerVersion, e.Required):
+		return msg + " and the Docker host only speaks " + e.ServerVersion
+	}
+	return msg + ": the Docker host speaks " + e.ServerVersion + " but dockhand's Docker client only " + MaxAPIVersion
+}
+
+// NegotiateAPIVersion asks the Docker host which API version it speaks and settles on the
+// newest one both sides know. It fails when the host is older than MinAPIVersion.
+func (d *Host) NegotiateAPIVersion(ctx context.Context) error {
+
+	ping, err := d.DockerCli.Ping(ctx)
+	if err != nil {
+		return err
+	}
+	version, err := negotiateAPIVersion(ping.APIVersion)
+	if err != nil {
+		return err
+	}
+	d.ServerAPIVersion = ping.APIVersion
+	if d.ServerAPIVersion == "" {
+		d.ServerAPIVersion = unreportedAPIVersion
+	}
+	d.DockerCli.UpdateClientVersion(version)
+	if d.buildCli != nil {
+		d.buildCli.UpdateClientVersion(version)
+	}
+	return nil
+}
+
+// negotiateAPIVersion returns the API version to use with a daemon speaking server
+func negotiateAPIVersion(server string) (string, error) {
+
+	if server == "" {
+		server = unreportedAPIVersion
+	}
+	if versions.LessThan(server, MinAPIVersion) {
+		return "", errors.New("the Docker host speaks API " + server + ", dockhand needs at least " + MinAPIVersion)
+	}
+	if versions.LessThan(server, MaxAPIVersion) {
+		return server, nil
+	}
+	return MaxAPIVersion, nil
+}
+
+// APIVersion is the Docker API version requests to the host use
+func (d *Host) APIVersion() string {
+	return d.DockerCli.ClientVersion()
+}
+
+// RequireAPIVersion returns an *APIVersionError when feature needs a newer API than the connection uses,
+// or for the features dockhand sends itself, than the Docker host speaks
+func (d *Host) RequireAPIVersion(feature string) error {
+
+	version := d.APIVersion()
+	if sentByDockhand[feature] && d.ServerAPIVersion != "" {
+		version = d.ServerAPIVersion
+	}
+	return requireAPIVersion(feature, version, d.ServerAPIVersion)
+}
+
+func requireAPIVersion(feature, version, server string) error {
+
+	required, ok := featureAPIVersions[feature]
+	if !ok || !versions.LessThan(version, required) {
+		return nil
+	}
+	return &APIVersionError{Feature: feature, Required: required, Version: version, ServerVersion: server}
+}
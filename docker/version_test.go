@@ -0,0 +1,66 @@
+package docker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNegotiateAPIVersion(t *testing.T) {
+
+	tests := []struct {
+		server string
+		want   string
+		fails  bool
+	}{
+		{server: "1.30", want: MaxAPIVersion},
+		{server: MaxAPIVersion, want: MaxAPIVersion},
+		{server: "1.25", want: "1.25"},
+		{server: "", want: unreportedAPIVersion},
+		{server: "1.22", fails: true},
+	}
+
+	for _, test := range tests {
+		got, err := negotiateAPIVersion(test.server)
+		if test.fails {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %s", test.server, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("%q: got %s, %v, want %s", test.server, got, err, test.want)
+		}
+	}
+}
+
+func TestRequireAPIVersion(t *testing.T) {
+
+	if err := requireAPIVersion(FeatureBuildTarget, "1.30", "1.30"); err != nil {
+		t.Errorf("target with API 1.30: unexpected error %v", err)
+	}
+	if err := requireAPIVersion("something new", "1.24", "1.24"); err != nil {
+		t.Errorf("unknown feature: unexpected error %v", err)
+	}
+
+	tests := []struct {
+		server string
+		says   string
+	}{
+		{server: "1.25", says: "the Docker host only speaks 1.25"},
+		{server: "1.35", says: "dockhand's Docker client only " + MaxAPIVersion},
+	}
+	for _, test := range tests {
+		err := requireAPIVersion(FeatureBuildTarget, "1.25", test.server)
+		versionErr, ok := err.(*APIVersionError)
+		if !ok {
+			t.Errorf("server %s: got %v, want an *APIVersionError", test.server, err)
+			continue
+		}
+		if versionErr.Required != "1.29" {
+			t.Errorf("server %s: required %s, want 1.29", test.server, versionErr.Required)
+		}
+		if !strings.Contains(err.Error(), test.says) {
+			t.Errorf("server %s: %q does not say %q", test.server, err, test.says)
+		}
+	}
+}
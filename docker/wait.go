@@ -0,0 +1,87 @@
+package docker
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// WaitResult is how a container finished
+type WaitResult struct {
+	StatusCode int
+	// OOMKilled is set when the kernel killed the container for running out of memory
+	OOMKilled bool
+	// Duration is how long the container ran
+	Duration time.Duration
+}
+
+// WaitTimeoutError is returned when a container is still running when the wait times out
+type WaitTimeoutError struct {
+	ContainerID string
+	Timeout     time.Duration
+}
+
+func (e *WaitTimeoutError) Error() string {
+	return "container " + shortID(e.ContainerID) + " still running after " + e.Timeout.String()
+}
+
+// WaitContainer blocks until the container exits and returns its exit status, whether it ran out
+// of memory and how long it ran. It gives up with a *WaitTimeoutError after timeout (no limit when
+// 0) and with ctx.Err() when ctx is cancelled; the container is left running either way.
+func (d *Host) WaitContainer(ctx context.Context, containerID string, timeout time.Duration) (WaitResult, error) {
+
+	waitStarted := time.Now()
+	waitCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	statusCode, err := d.DockerCli.ContainerWait(waitCtx, containerID)
+	if err != nil {
+		if ctx.Err() == nil && waitCtx.Err() == context.DeadlineExceeded {
+			return WaitResult{}, &WaitTimeoutError{ContainerID: containerID, Timeout: timeout}
+		}
+		if ctx.Err() != nil {
+			return WaitResult{}, ctx.Err()
+		}
+		return WaitResult{}, err
+	}
+
+	result := WaitResult{StatusCode: int(statusCode), Duration: time.Since(waitStarted)}
+	info, err := d.DockerCli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return result, err
+	}
+	if info.ContainerJSONBase != nil && info.State != nil {
+		result.OOMKilled = info.State.OOMKilled
+		if duration, ok := runTime(info.State.StartedAt, info.State.FinishedAt); ok {
+			result.Duration = duration
+		}
+	}
+	return result, nil
+}
+
+// runTime is the time between the StartedAt and FinishedAt of a container state
+func runTime(startedAt, finishedAt string) (time.Duration, bool) {
+
+	started, err := time.Parse(time.RFC3339Nano, startedAt)
+	if err != nil || started.IsZero() {
+		return 0, false
+	}
+	finished, err := time.Parse(time.RFC3339Nano, finishedAt)
+	if err != nil || finished.Before(started) {
+		return 0, false
+	}
+	return finished.Sub(started), true
+}
+
+// shortID is the 12 character form of a container ID docker shows
+func shortID(id string) string {
+
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
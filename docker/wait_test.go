@@ -0,0 +1,36 @@
+package docker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRunTime(t *testing.T) {
+
+	tests := []struct {
+		started, finished string
+		want              time.Duration
+		ok                bool
+	}{
+		{"2017-03-01T10:00:00.5Z", "2017-03-01T10:00:02Z", 1500 * time.Millisecond, true},
+		// still running
+		{"2017-03-01T10:00:00Z", "0001-01-01T00:00:00Z", 0, false},
+		// never started
+		{"0001-01-01T00:00:00Z", "0001-01-01T00:00:00Z", 0, false},
+		{"", "", 0, false},
+	}
+	for _, test := range tests {
+		got, ok := runTime(test.started, test.finished)
+		if got != test.want || ok != test.ok {
+			t.Errorf("runTime(%q, %q) = %v, %v, want %v, %v", test.started, test.finished, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestWaitTimeoutError(t *testing.T) {
+
+	err := &WaitTimeoutError{ContainerID: "4a5573037f358b6cdfa2f3e8a9c33a5c", Timeout: 10 * time.Second}
+	if want := "container 4a5573037f35 still running after 10s"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
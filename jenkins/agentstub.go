@@ -0,0 +1,158 @@
+package jenkins
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AgentStub is a stand-in Jenkins master that JNLP agents can connect to. It serves the agent's
+// .jnlp file and the TCP agent listener and records the protocol agents announce when they connect,
+// which proves an image can start an agent and reach a master, without a real Jenkins.
+type AgentStub struct {
+	// URL is the Jenkins URL the agent is given
+	URL string
+	// Name and Secret identify the agent, as they would for a node on a real master
+	Name   string
+	Secret string
+
+	http       net.Listener
+	agents     net.Listener
+	handshakes chan string
+}
+
+// pingProtocol is the protocol agents use to check the agent port is reachable before connecting
+const pingProtocol = "Ping"
+
+// NewAgentStub starts the stub listening on host (all interfaces when empty).
+// advertiseHost is the address agents reach the stub on, e.g. the Docker bridge gateway.
+func NewAgentStub(host, advertiseHost string) (*AgentStub, error) {
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	httpListener, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return nil, err
+	}
+	agentListener, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		httpListener.Close()
+		return nil, err
+	}
+
+	s := &AgentStub{
+		URL:        "http://" + net.JoinHostPort(advertiseHost, strconv.Itoa(httpListener.Addr().(*net.TCPAddr).Port)) + "/",
+		Name:       "dockhand-check",
+		Secret:     hex.EncodeToString(secret),
+		http:       httpListener,
+		agents:     agentListener,
+		handshakes: make(chan string, 1),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/computer/"+s.Name+"/slave-agent.jnlp", s.serveJNLP)
+	mux.HandleFunc("/tcpSlaveAgentListener/", s.serveListenerInfo)
+	go http.Serve(httpListener, mux)
+	go s.acceptAgents()
+	return s, nil
+}
+
+// JNLPURL is the URL of the agent's .jnlp file, for the agent's -jnlpUrl option
+func (s *AgentStub) JNLPURL() string {
+	return s.URL + "computer/" + s.Name + "/slave-agent.jnlp"
+}
+
+// WaitForHandshake waits until an agent connects and returns the protocol it asked for
+func (s *AgentStub) WaitForHandshake(timeout time.Duration) (string, error) {
+
+	select {
+	case protocol := <-s.handshakes:
+		return protocol, nil
+	case <-time.After(timeout):
+		return "", errors.New("no agent connected to " + s.URL + " within " + timeout.String())
+	}
+}
+
+// Close stops the stub
+func (s *AgentStub) Close() error {
+
+	s.agents.Close()
+	return s.http.Close()
+}
+
+func (s *AgentStub) serveJNLP(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/x-java-jnlp-file")
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<jnlp codebase="%[1]scomputer/%[2]s/" spec="1.0+">
+  <information><title>Agent for %[2]s</title><vendor>Dockhand</vendor></information>
+  <application-desc main-class="hudson.remoting.jnlp.Main">
+    <argument>%[3]s</argument>
+    <argument>%[2]s</argument>
+    <argument>-url</argument>
+    <argument>%[1]s</argument>
+  </application-desc>
+</jnlp>
+`, s.URL, s.Name, s.Secret)
+}
+
+func (s *AgentStub) serveListenerInfo(w http.ResponseWriter, r *http.Request) {
+
+	port := s.agents.Addr().(*net.TCPAddr).Port
+	w.Header().Set("X-Jenkins-JNLP-Port", strconv.Itoa(port))
+	w.Header().Set("X-Jenkins-Agent-Protocols", "JNLP4-connect, JNLP-connect, "+pingProtocol)
+	w.Header().Set("X-Hudson-JNLP-Port", strconv.Itoa(port))
+	fmt.Fprintln(w, "Jenkins")
+}
+
+func (s *AgentStub) acceptAgents() {
+
+	for {
+		conn, err := s.agents.Accept()
+		if err != nil {
+			return
+		}
+		go s.handleAgent(conn)
+	}
+}
+
+// handleAgent reads the protocol announcement agents open the connection with,
+// a Java DataOutputStream.writeUTF of "Protocol:<name>"
+func (s *AgentStub) handleAgent(conn net.Conn) {
+
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	var length uint16
+	if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+		return
+	}
+	announcement := make([]byte, length)
+	if _, err := io.ReadFull(conn, announcement); err != nil {
+		return
+	}
+	protocol := strings.TrimPrefix(string(announcement), "Protocol:")
+	if protocol == string(announcement) {
+		return
+	}
+
+	if protocol == pingProtocol {
+		conn.Write([]byte("Ping\n"))
+		return
+	}
+	select {
+	case s.handshakes <- protocol:
+	default:
+	}
+}
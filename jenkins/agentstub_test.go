@@ -0,0 +1,85 @@
+package jenkins
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// connectAgent does what a JNLP agent does on startup: fetch the .jnlp file, look up the
+// agent port and announce the protocols it wants to speak
+func connectAgent(t *testing.T, stub *AgentStub, protocols ...string) {
+
+	response, err := http.Get(stub.JNLPURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	jnlp, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if !strings.Contains(string(jnlp), "<argument>"+stub.Secret+"</argument>") {
+		t.Errorf("the .jnlp file does not pass the secret:\n%s", jnlp)
+	}
+
+	response, err = http.Get(stub.URL + "tcpSlaveAgentListener/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	port := response.Header.Get("X-Jenkins-JNLP-Port")
+	if port == "" {
+		t.Fatal("no X-Jenkins-JNLP-Port header")
+	}
+
+	for _, protocol := range protocols {
+		conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", port))
+		if err != nil {
+			t.Fatal(err)
+		}
+		announcement := "Protocol:" + protocol
+		binary.Write(conn, binary.BigEndian, uint16(len(announcement)))
+		conn.Write([]byte(announcement))
+		if protocol == pingProtocol {
+			reply, _ := ioutil.ReadAll(conn)
+			if string(reply) != "Ping\n" {
+				t.Errorf("ping reply %q", reply)
+			}
+		}
+		conn.Close()
+	}
+}
+
+func TestAgentStub(t *testing.T) {
+
+	stub, err := NewAgentStub("127.0.0.1", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stub.Close()
+
+	connectAgent(t, stub, pingProtocol, "JNLP4-connect")
+	protocol, err := stub.WaitForHandshake(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if protocol != "JNLP4-connect" {
+		t.Errorf("handshake protocol %q, want JNLP4-connect", protocol)
+	}
+}
+
+func TestAgentStubTimeout(t *testing.T) {
+
+	stub, err := NewAgentStub("127.0.0.1", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stub.Close()
+
+	connectAgent(t, stub, pingProtocol)
+	if _, err := stub.WaitForHandshake(100 * time.Millisecond); err == nil {
+		t.Error("a ping counted as an agent handshake")
+	}
+}
@@ -0,0 +1,241 @@
+package jenkins
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Auth says how dockhand authenticates with Jenkins. BearerToken wins over APIToken, which wins
+// over Password; both of the latter go with Username as basic auth. CertFile and KeyFile are a
+// client certificate for Jenkins behind a proxy that asks for one, CAFile the CA that signed Jenkins.
+type Auth struct {
+	Username    string
+	Password    string
+	APIToken    string
+	BearerToken string
+	CertFile    string
+	KeyFile     string
+	CAFile      string
+}
+
+// Client is the HTTP client for every call dockhand makes to Jenkins. It authenticates each
+// request and sends the CSRF crumb with the ones that change something, fetching the crumb
+// once and again when Jenkins rejects it.
+type Client struct {
+	URL  string
+	HTTP *http.Client
+
+	auth Auth
+
+	mu         sync.Mutex
+	crumbField string
+	crumb      string
+	crumbKnown bool
+}
+
+// NewClient returns a client for the Jenkins at jenkinsURL
+func NewClient(jenkinsURL string, auth Auth) (*Client, error) {
+
+	tlsConfig, err := auth.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	base := &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig}
+
+	// Jenkins ties crumbs to the session, so the session cookie has to be kept
+	jar, _ := cookiejar.New(nil)
+	c := &Client{URL: strings.TrimSuffix(jenkinsURL, "/"), auth: auth}
+	c.HTTP = &http.Client{Jar: jar, Transport: &transport{client: c, base: base}}
+	return c, nil
+}
+
+// endpoint returns the URL of path on Jenkins with the query, escaping both
+func (c *Client) endpoint(path string, query url.Values) (string, error) {
+
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return "", errors.New("invalid Jenkins URL " + c.URL + ": " + err.Error())
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// tlsConfig loads the client certificate and CA, nil when neither is set
+func (a Auth) tlsConfig() (*tls.Config, error) {
+
+	if a.CertFile == "" && a.KeyFile == "" && a.CAFile == "" {
+		return nil, nil
+	}
+	config := &tls.Config{}
+	if a.CertFile != "" || a.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(a.CertFile, a.KeyFile)
+		if err != nil {
+			return nil, errors.New("cannot load the Jenkins client certificate: " + err.Error())
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if a.CAFile != "" {
+		pem, err := ioutil.ReadFile(a.CAFile)
+		if err != nil {
+			return nil, errors.New("cannot read the Jenkins CA: " + err.Error())
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates in the Jenkins CA file " + a.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+// authenticate sets the Authorization header of r
+func (a Auth) authenticate(r *http.Request) {
+
+	switch {
+	case a.BearerToken != "":
+		r.Header.Set("Authorization", "Bearer "+a.BearerToken)
+	case a.APIToken != "":
+		r.SetBasicAuth(a.Username, a.APIToken)
+	case a.Username != "" || a.Password != "":
+		r.SetBasicAuth(a.Username, a.Password)
+	}
+}
+
+// Crumb returns the CSRF crumb and the header to send it in, both empty when CSRF protection
+// is off. It is fetched from Jenkins the first time.
+func (c *Client) Crumb() (string, string, error) {
+
+	c.mu.Lock()
+	if c.crumbKnown {
+		defer c.mu.Unlock()
+		return c.crumbField, c.crumb, nil
+	}
+	c.mu.Unlock()
+
+	crumbURL, err := c.endpoint("/crumbIssuer/api/json", nil)
+	if err != nil {
+		return "", "", err
+	}
+	response, err := c.HTTP.Get(crumbURL)
+	if err != nil {
+		return "", "", err
+	}
+	defer response.Body.Close()
+
+	var crumb struct {
+		Crumb             string `json:"crumb"`
+		CrumbRequestField string `json:"crumbRequestField"`
+	}
+	switch response.StatusCode {
+	case 200:
+		if err := json.NewDecoder(response.Body).Decode(&crumb); err != nil {
+			return "", "", errors.New("cannot read the crumb from Jenkins: " + err.Error())
+		}
+	case 404:
+		// CSRF protection is off
+	default:
+		return "", "", errors.New("ERROR: Response code: " + strconv.Itoa(response.StatusCode) + " from " + crumbURL)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.crumbField, c.crumb, c.crumbKnown = crumb.CrumbRequestField, crumb.Crumb, true
+	return c.crumbField, c.crumb, nil
+}
+
+// forgetCrumb makes the next request fetch a new crumb
+func (c *Client) forgetCrumb() {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.crumbField, c.crumb, c.crumbKnown = "", "", false
+}
+
+// transport authenticates the requests to Jenkins and adds the crumb to those that need one
+type transport struct {
+	client *Client
+	base   http.RoundTripper
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+
+	if r.Method == "GET" || r.Method == "HEAD" {
+		request := cloneRequest(r)
+		t.client.auth.authenticate(request)
+		return t.base.RoundTrip(request)
+	}
+
+	// keep the body so the request can be sent again with a new crumb
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	response, err := t.send(r, body)
+	if err != nil || !crumbRejected(response) {
+		return response, err
+	}
+	response.Body.Close()
+	t.client.forgetCrumb()
+	return t.send(r, body)
+}
+
+// send sends r with body, the crumb and the credentials
+func (t *transport) send(r *http.Request, body []byte) (*http.Response, error) {
+
+	field, crumb, err := t.client.Crumb()
+	if err != nil {
+		return nil, err
+	}
+	request := cloneRequest(r)
+	t.client.auth.authenticate(request)
+	if field != "" {
+		request.Header.Set(field, crumb)
+	}
+	if body != nil {
+		request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		request.ContentLength = int64(len(body))
+	}
+	return t.base.RoundTrip(request)
+}
+
+// crumbRejected reports whether Jenkins refused the request for a missing or expired crumb,
+// leaving the response body readable
+func crumbRejected(response *http.Response) bool {
+
+	if response.StatusCode != http.StatusForbidden {
+		return false
+	}
+	body, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return bytes.Contains(body, []byte("No valid crumb"))
+}
+
+// cloneRequest returns a copy of r with its own headers, a RoundTripper must not change r
+func cloneRequest(r *http.Request) *http.Request {
+
+	clone := new(http.Request)
+	*clone = *r
+	clone.Header = make(http.Header, len(r.Header))
+	for name, values := range r.Header {
+		clone.Header[name] = append([]string(nil), values...)
+	}
+	return clone
+}
@@ -0,0 +1,153 @@
+package jenkins
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stevebargelt/Dockhand/jenkins/jenkinstest"
+)
+
+func newClient(t *testing.T, server *jenkinstest.Server, auth Auth) *Client {
+
+	client, err := NewClient(server.URL, auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// count returns how many of the requests the server got start with prefix
+func count(server *jenkinstest.Server, prefix string) int {
+
+	n := 0
+	for _, request := range server.Requests() {
+		if strings.HasPrefix(request, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestClientAuth(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		auth   Auth
+		status int
+	}{
+		{name: "password", auth: Auth{Username: "admin", Password: "secret"}, status: http.StatusOK},
+		{name: "API token", auth: Auth{Username: "admin", APIToken: "11deadbeef"}, status: http.StatusOK},
+		{name: "API token over password", auth: Auth{Username: "admin", Password: "wrong", APIToken: "11deadbeef"}, status: http.StatusOK},
+		{name: "bearer token", auth: Auth{BearerToken: "oidc-token"}, status: http.StatusOK},
+		{name: "wrong password", auth: Auth{Username: "admin", Password: "wrong"}, status: http.StatusUnauthorized},
+		{name: "no credentials", auth: Auth{}, status: http.StatusUnauthorized},
+	}
+
+	server := newJenkins(false)
+	defer server.Close()
+	server.APIToken = "11deadbeef"
+	server.Token = "oidc-token"
+
+	for _, test := range tests {
+		response, err := newClient(t, server, test.auth).HTTP.Get(server.URL + "/api/json")
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		response.Body.Close()
+		if response.StatusCode != test.status {
+			t.Errorf("%s: status %d, want %d", test.name, response.StatusCode, test.status)
+		}
+	}
+}
+
+func TestClientCrumb(t *testing.T) {
+
+	server := newJenkins(false)
+	defer server.Close()
+	backend := &ConsoleBackend{Client: newClient(t, server, Auth{Username: server.Username, Password: server.Password})}
+
+	for _, label := range []string{"TeamB", "TeamC"} {
+		if created, err := CreateDockerTemplate(backend, "docker", DockerTemplate{Label: label, Image: "example/slave"}); err != nil || !created {
+			t.Fatalf("%s: created %v, %v", label, created, err)
+		}
+	}
+	if n := count(server, "GET /crumbIssuer/"); n != 1 {
+		t.Errorf("crumb fetched %d times, want once", n)
+	}
+
+	// Jenkins rejects the crumb once the session ends, the client fetches a new one and retries
+	server.ExpireCrumb()
+	if removed, err := RemoveDockerTemplate(backend, "docker", "TeamB"); err != nil || !removed {
+		t.Fatalf("after the crumb expired: removed %v, %v", removed, err)
+	}
+	if n := count(server, "GET /crumbIssuer/"); n != 2 {
+		t.Errorf("crumb fetched %d times, want twice", n)
+	}
+	if n := count(server, "POST /scriptText"); n != 4 {
+		t.Errorf("%d scripts sent, want 4 with the retry", n)
+	}
+}
+
+func TestClientWithoutCrumbIssuer(t *testing.T) {
+
+	server := newJenkins(false)
+	defer server.Close()
+	server.CSRF = false
+	server.Fail("/crumbIssuer/", http.StatusNotFound)
+
+	client := newClient(t, server, Auth{Username: server.Username, Password: server.Password})
+	response, err := client.HTTP.Post(server.URL+"/createItem?name=TeamB", "application/xml", strings.NewReader("<project/>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("status %d", response.StatusCode)
+	}
+	if _, ok := server.Job("TeamB"); !ok {
+		t.Error("job not created")
+	}
+}
+
+func TestClientCertificate(t *testing.T) {
+
+	if _, err := NewClient("https://jenkins.example.com", Auth{CertFile: "missing/cert.pem", KeyFile: "missing/key.pem"}); err == nil {
+		t.Error("missing client certificate: expected an error")
+	}
+	if _, err := NewClient("https://jenkins.example.com", Auth{CAFile: "missing/ca.pem"}); err == nil {
+		t.Error("missing CA: expected an error")
+	}
+}
+
+func TestInitClientSharesTheClient(t *testing.T) {
+
+	server := newJenkins(false)
+	defer server.Close()
+	client := newClient(t, server, Auth{Username: server.Username, Password: server.Password})
+
+	jenkins, err := InitClient(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jenkins.Requester.Client != client.HTTP {
+		t.Error("gojenkins does not send its requests through the client")
+	}
+}
+
+func TestClientEndpoint(t *testing.T) {
+
+	client, err := NewClient("https://ci.example.com/jenkins/", Auth{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := client.endpoint("/scriptler/run/getLabels.groovy", url.Values{"cloudName": {"Azure & Co"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://ci.example.com/jenkins/scriptler/run/getLabels.groovy?cloudName=Azure+%26+Co"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
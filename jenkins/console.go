@@ -0,0 +1,37 @@
+package jenkins
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+)
+
+// ConsoleBackend runs the scripts on the Jenkins script console through /scriptText,
+// which needs an administrator account but no plugin
+type ConsoleBackend struct {
+	Client *Client
+}
+
+// Run binds the parameters into the script, runs it and returns what it printed
+func (b *ConsoleBackend) Run(script Script, params map[string]string) (string, error) {
+
+	scriptURL, err := b.Client.endpoint("/scriptText", nil)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{"script": {bind(script, params)}}
+	response, err := b.Client.HTTP.PostForm(scriptURL, form)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	if response.StatusCode != 200 {
+		return "", errors.New("ERROR: Response code: " + strconv.Itoa(response.StatusCode) + " running " + script.Name + " on " + scriptURL)
+	}
+	return string(body), nil
+}
@@ -2,8 +2,10 @@ package jenkins
 
 import "github.com/bndr/gojenkins"
 
-//InitClient initializes the Jenkins client - connects to jenkins instance
-func InitClient(jenkinsURL string, username string, password string) (*gojenkins.Jenkins, error) {
-	jenkins, err := gojenkins.CreateJenkins(jenkinsURL, username, password).Init()
-	return jenkins, err
+//InitClient initializes the Jenkins client - connects to jenkins instance.
+//gojenkins sends its requests through client, which authenticates them and adds the crumb
+func InitClient(client *Client) (*gojenkins.Jenkins, error) {
+	jenkins := gojenkins.CreateJenkins(client.URL)
+	jenkins.Requester.Client = client.HTTP
+	return jenkins.Init()
 }
@@ -6,73 +6,119 @@ import (
 	"errors"
 	"io"
 	"net/http"
-	"strings"
+	"net/url"
+	"strconv"
 )
 
-//CheckLabelIsUnique checks jenkins to see if a label already exists
-//it will return false if the label DOES exists and true if it does not exist
-func CheckLabelIsUnique(jenkinsURL string, cloudName string, label string, username string, password string) (bool, error) {
+//CheckLabelIsUnique checks jenkins to see if a label already exists in the cloud, comparing labels as match says.
+//it will return false if the label DOES exists and true if it does not exist, along with the labels that exist
+func CheckLabelIsUnique(backend Backend, cloudName string, label string, match LabelMatch) (bool, []string, error) {
 
-	//TODO: LOG
+	if err := validate(checkCloudName(cloudName), checkLabel(label)); err != nil {
+		return false, nil, err
+	}
+	labels, err := GetLabels(backend, cloudName)
+	if err != nil {
+		return false, nil, err
+	}
 
-	client := &http.Client{}
-	url := jenkinsURL + "/scriptler/run/getLabels.groovy?cloudName=" + cloudName
-	r, err := http.NewRequest("GET", url, nil)
-	r.Header.Add("Accept-Encoding", "gzip")
-	r.SetBasicAuth(username, password)
+	for _, existing := range labels {
+		if match.Equal(existing, label) {
+			return false, labels, nil
+		}
+	}
+	return true, labels, nil
+}
 
-	response, err := client.Do(r)
+//GetLabels returns the labels of the docker slave templates in the cloud
+func GetLabels(backend Backend, cloudName string) ([]string, error) {
+
+	if err := validate(checkCloudName(cloudName)); err != nil {
+		return nil, err
+	}
+	body, err := backend.Run(getLabelsScript, map[string]string{"cloudName": cloudName})
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	defer response.Body.Close()
+	return parseLabels(body)
+}
 
-	// Check that the server actually sent compressed data
-	var reader io.ReadCloser
-	switch response.Header.Get("Content-Encoding") {
-	case "gzip":
-		reader, err = gzip.NewReader(response.Body)
-		defer reader.Close()
-	default:
-		reader = response.Body
+//TemplateImage returns the image of the slave template with the given label in the cloud,
+//false when the cloud has no template for it
+func TemplateImage(backend Backend, cloudName string, label string) (string, bool, error) {
+
+	if err := validate(checkCloudName(cloudName), checkLabel(label)); err != nil {
+		return "", false, err
 	}
+	body, err := backend.Run(getTemplateImageScript, map[string]string{"cloudName": cloudName, "label": label})
+	if err != nil {
+		return "", false, err
+	}
+	return parseImage(body)
+}
 
-	buf := new(bytes.Buffer)
-	buf.ReadFrom(reader)
-	body := buf.String()
+//CreateDockerTemplate calls a script on the jenkins instance to create the slave template
+//in the cloud, its label must be unique.
+//An invalid template is refused with a *ValidationError before anything is sent
+func CreateDockerTemplate(backend Backend, cloudName string, template DockerTemplate) (bool, error) {
 
-	if response.StatusCode != 200 {
-		err := errors.New("ERROR: Response code: " + string(response.StatusCode) + " from " + url)
+	if err := validate(append([]*FieldError{checkCloudName(cloudName)}, template.checks()...)...); err != nil {
 		return false, err
 	}
-
+	body, err := backend.Run(createDockerTemplateScript, map[string]string{"cloudName": cloudName, "template": template.definition()})
 	if err != nil {
 		return false, err
 	}
+	return parseResult(createDockerTemplateScript, body)
+}
+
+//RemoveDockerTemplate calls a script on the jenkins instance to remove the slave template
+//with the given label from the cloud
+func RemoveDockerTemplate(backend Backend, cloudName string, label string) (bool, error) {
 
-	if strings.Contains(string(body), label) {
-		//TODO: log
-		//fmt.Println("Label:", label, "already exists. will return false.")
-		return false, nil
+	if err := validate(checkCloudName(cloudName), checkLabel(label)); err != nil {
+		return false, err
 	}
-	return true, nil
+	body, err := backend.Run(removeDockerTemplateScript, map[string]string{"cloudName": cloudName, "label": label})
+	if err != nil {
+		return false, err
+	}
+	return parseResult(removeDockerTemplateScript, body)
 }
 
-//CreateDockerTemplate calls a script on the jenkins instance to create a slave template
-//given the cloundname, label (must be unique) and dockerImage to use
-func CreateDockerTemplate(jenkinsURL string, cloudName string, label string, dockerImage string, username string, password string) (bool, error) {
+// ScriptlerBackend runs the scripts installed in the Scriptler plugin under their names
+type ScriptlerBackend struct {
+	Client *Client
+}
 
-	client := &http.Client{}
+// Run runs the Scriptler script with the parameters and returns what it printed
+func (b *ScriptlerBackend) Run(script Script, params map[string]string) (string, error) {
 
-	url := jenkinsURL + "/scriptler/run/createDockerTemplate.groovy?cloudName=" + cloudName + "&label=" + label + "&image=" + dockerImage
+	query := url.Values{}
+	for _, name := range script.Params {
+		query.Set(name, params[name])
+	}
+	return runScriptlerScript(b.Client, script.Name+".groovy", query)
+}
 
-	r, err := http.NewRequest("GET", url, nil)
+// runScriptlerScript runs a Scriptler script with the query parameters on the jenkins instance
+// and returns the body of the response
+func runScriptlerScript(client *Client, script string, query url.Values) (string, error) {
+
+	scriptURL, err := client.endpoint("/scriptler/run/"+script, query)
+	if err != nil {
+		return "", err
+	}
+
+	r, err := http.NewRequest("GET", scriptURL, nil)
+	if err != nil {
+		return "", err
+	}
 	r.Header.Add("Accept-Encoding", "gzip")
-	r.SetBasicAuth(username, password)
 
-	response, err := client.Do(r)
+	response, err := client.HTTP.Do(r)
 	if err != nil {
-		return false, err
+		return "", err
 	}
 	defer response.Body.Close()
 
@@ -81,24 +127,22 @@ func CreateDockerTemplate(jenkinsURL string, cloudName string, label string, doc
 	switch response.Header.Get("Content-Encoding") {
 	case "gzip":
 		reader, err = gzip.NewReader(response.Body)
+		if err != nil {
+			return "", err
+		}
 		defer reader.Close()
 	default:
 		reader = response.Body
 	}
 
 	buf := new(bytes.Buffer)
-	buf.ReadFrom(reader)
-	body := buf.String()
-
-	if response.StatusCode != 200 {
-		err := errors.New("ERROR: Response code: " + string(response.StatusCode) + " from " + url)
-		return false, err
+	if _, err := buf.ReadFrom(reader); err != nil {
+		return "", err
 	}
 
-	if strings.Contains(string(body), "false") {
-		//TODO: log
-		return false, nil
+	if response.StatusCode != 200 {
+		return "", errors.New("ERROR: Response code: " + strconv.Itoa(response.StatusCode) + " from " + scriptURL)
 	}
 
-	return true, nil
+	return buf.String(), nil
 }
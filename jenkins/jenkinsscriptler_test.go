@@ -0,0 +1,312 @@
+package jenkins
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stevebargelt/Dockhand/jenkins/jenkinstest"
+)
+
+// newJenkins starts a fake Jenkins with CSRF protection, a docker cloud holding templates for TeamA and DotNetCore23
+// and a shared cloud with a template for both DotNet and linux
+func newJenkins(gzip bool) *jenkinstest.Server {
+
+	server := jenkinstest.NewServer()
+	server.Username = "admin"
+	server.Password = "secret"
+	server.Gzip = gzip
+	server.CSRF = true
+	server.AddCloud("docker", "TeamA", "DotNetCore23")
+	server.AddCloud("shared", "DotNet linux")
+	return server
+}
+
+// backends are the ways the tests run the scripts on the fake Jenkins
+var backends = []string{BackendConsole, BackendScriptler}
+
+func newBackend(t *testing.T, kind string, server *jenkinstest.Server, password string) Backend {
+
+	backend, err := NewBackend(kind, newClient(t, server, Auth{Username: server.Username, Password: password}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backend
+}
+
+func TestCheckLabelIsUnique(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		cloud    string
+		label    string
+		password string
+		match    LabelMatch
+		gzip     bool
+		fail     int
+		unique   bool
+		wantErr  bool
+	}{
+		{name: "new label", cloud: "docker", label: "TeamB", unique: true},
+		{name: "existing label", cloud: "docker", label: "TeamA"},
+		{name: "prefix of a label", cloud: "docker", label: "DotNet", unique: true},
+		{name: "label containing one", cloud: "docker", label: "TeamAB", unique: true},
+		{name: "other case", cloud: "docker", label: "teama", unique: true},
+		{name: "other case ignored", cloud: "docker", label: "teama", match: MatchIgnoreCase},
+		{name: "gzip response", cloud: "docker", label: "TeamA", gzip: true},
+		{name: "gzip new label", cloud: "docker", label: "TeamB", gzip: true, unique: true},
+		{name: "unknown cloud", cloud: "swarm", label: "TeamA", unique: true},
+		{name: "first of a template's labels", cloud: "shared", label: "DotNet"},
+		{name: "second of a template's labels", cloud: "shared", label: "linux"},
+		{name: "other case of a template's label", cloud: "shared", label: "Linux", unique: true},
+		{name: "wrong password", cloud: "docker", label: "TeamB", password: "wrong", wantErr: true},
+		{name: "scriptler missing", cloud: "docker", label: "TeamB", fail: http.StatusNotFound, wantErr: true},
+		{name: "server error", cloud: "docker", label: "TeamB", fail: http.StatusInternalServerError, wantErr: true},
+	}
+
+	for _, kind := range backends {
+		for _, test := range tests {
+			server := newJenkins(test.gzip)
+			if test.fail != 0 {
+				server.Fail("/scriptler/", test.fail)
+				server.Fail("/scriptText", test.fail)
+			}
+			password := server.Password
+			if test.password != "" {
+				password = test.password
+			}
+
+			unique, labels, err := CheckLabelIsUnique(newBackend(t, kind, server, password), test.cloud, test.label, test.match)
+			server.Close()
+			if (err != nil) != test.wantErr {
+				t.Errorf("%s %s: error %v, want error %v", kind, test.name, err, test.wantErr)
+				continue
+			}
+			if err == nil && unique != test.unique {
+				t.Errorf("%s %s: unique %v, want %v", kind, test.name, unique, test.unique)
+			}
+			if test.cloud == "docker" && err == nil && strings.Join(labels, ",") != "DotNetCore23,TeamA" {
+				t.Errorf("%s %s: labels %v", kind, test.name, labels)
+			}
+			if test.cloud == "shared" && err == nil && strings.Join(labels, ",") != "DotNet,linux" {
+				t.Errorf("%s %s: labels %v", kind, test.name, labels)
+			}
+		}
+	}
+}
+
+func TestCreateDockerTemplate(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		cloud   string
+		label   string
+		gzip    bool
+		fail    int
+		created bool
+		wantErr bool
+	}{
+		{name: "new label", cloud: "docker", label: "TeamB", created: true},
+		{name: "new label gzip", cloud: "docker", label: "TeamB", gzip: true, created: true},
+		{name: "existing label", cloud: "docker", label: "TeamA"},
+		{name: "unknown cloud", cloud: "swarm", label: "TeamB"},
+		{name: "one of a template's labels", cloud: "shared", label: "linux"},
+		{name: "server error", cloud: "docker", label: "TeamB", fail: http.StatusInternalServerError, wantErr: true},
+	}
+
+	for _, kind := range backends {
+		for _, test := range tests {
+			server := newJenkins(test.gzip)
+			if test.fail != 0 {
+				server.Fail("/scriptler/", test.fail)
+				server.Fail("/scriptText", test.fail)
+			}
+
+			created, err := CreateDockerTemplate(newBackend(t, kind, server, server.Password), test.cloud, DockerTemplate{Label: test.label, Image: "example/slave:1.0"})
+			templates := server.Templates("docker")
+			server.Close()
+			if (err != nil) != test.wantErr {
+				t.Errorf("%s %s: error %v, want error %v", kind, test.name, err, test.wantErr)
+				continue
+			}
+			if created != test.created {
+				t.Errorf("%s %s: created %v, want %v", kind, test.name, created, test.created)
+			}
+			if test.created && templates[test.label] != "example/slave:1.0" {
+				t.Errorf("%s %s: templates %v", kind, test.name, templates)
+			}
+		}
+	}
+}
+
+func TestRemoveDockerTemplate(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		cloud       string
+		label       string
+		fail        int
+		removed     bool
+		labelString string
+		wantErr     bool
+	}{
+		{name: "existing label", cloud: "docker", label: "TeamA", removed: true, labelString: "TeamA"},
+		{name: "unknown label", cloud: "docker", label: "TeamB"},
+		{name: "one of a template's labels", cloud: "shared", label: "linux", removed: true, labelString: "DotNet linux"},
+		{name: "forbidden", cloud: "docker", label: "TeamA", fail: http.StatusForbidden, wantErr: true},
+	}
+
+	for _, kind := range backends {
+		for _, test := range tests {
+			server := newJenkins(false)
+			if test.fail != 0 {
+				server.Fail("/scriptler/", test.fail)
+				server.Fail("/scriptText", test.fail)
+			}
+
+			removed, err := RemoveDockerTemplate(newBackend(t, kind, server, server.Password), test.cloud, test.label)
+			templates := server.Templates(test.cloud)
+			server.Close()
+			if (err != nil) != test.wantErr {
+				t.Errorf("%s %s: error %v, want error %v", kind, test.name, err, test.wantErr)
+				continue
+			}
+			if removed != test.removed {
+				t.Errorf("%s %s: removed %v, want %v", kind, test.name, removed, test.removed)
+			}
+			if _, left := templates[test.labelString]; test.removed && left {
+				t.Errorf("%s %s: template still there: %v", kind, test.name, templates)
+			}
+		}
+	}
+}
+
+func TestTemplateImage(t *testing.T) {
+
+	for _, kind := range backends {
+		server := newJenkins(false)
+		backend := newBackend(t, kind, server, server.Password)
+
+		image, found, err := TemplateImage(backend, "docker", "TeamA")
+		if err != nil || !found || image != "TeamA-image" {
+			t.Errorf("%s existing label: %q, %v, %v", kind, image, found, err)
+		}
+		if image, found, err := TemplateImage(backend, "docker", "TeamB"); err != nil || found {
+			t.Errorf("%s unknown label: %q, %v, %v", kind, image, found, err)
+		}
+		if image, found, err := TemplateImage(backend, "shared", "linux"); err != nil || !found || image != "DotNet-image" {
+			t.Errorf("%s one of a template's labels: %q, %v, %v", kind, image, found, err)
+		}
+		if _, found, err := TemplateImage(backend, "swarm", "TeamA"); err != nil || found {
+			t.Errorf("%s unknown cloud: %v, %v", kind, found, err)
+		}
+		server.Close()
+	}
+
+	if _, _, err := parseImage("<html>Scriptler error</html>"); err == nil {
+		t.Error("HTML output: expected an error")
+	}
+}
+
+func TestRunScriptlerScriptError(t *testing.T) {
+
+	server := newJenkins(false)
+	defer server.Close()
+	server.Fail("/scriptler/", http.StatusInternalServerError)
+
+	_, err := runScriptlerScript(newClient(t, server, Auth{Username: server.Username, Password: server.Password}), "getLabels.groovy", url.Values{"cloudName": {"docker"}})
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("got %v, want an error with the response code", err)
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+
+	tests := []struct {
+		body    string
+		want    []string
+		wantErr bool
+	}{
+		{body: `["TeamA","DotNetCore23"]`, want: []string{"TeamA", "DotNetCore23"}},
+		{body: "[]\n", want: []string{}},
+		{body: `["DotNet linux","TeamA"]`, want: []string{"DotNet", "linux", "TeamA"}},
+		{body: "TeamA DotNetCore23", wantErr: true},
+		{body: "<html>Scriptler error</html>", wantErr: true},
+	}
+	for _, test := range tests {
+		labels, err := parseLabels(test.body)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q: error %v, want error %v", test.body, err, test.wantErr)
+			continue
+		}
+		if strings.Join(labels, ",") != strings.Join(test.want, ",") {
+			t.Errorf("%q: labels %v, want %v", test.body, labels, test.want)
+		}
+	}
+}
+
+func TestSuggestLabel(t *testing.T) {
+
+	existing := []string{"DotNet", "DotNet2", "dotnet3"}
+	if got := SuggestLabel("DotNet", existing, MatchExact); got != "DotNet3" {
+		t.Errorf("exact: got %s", got)
+	}
+	if got := SuggestLabel("DotNet", existing, MatchIgnoreCase); got != "DotNet4" {
+		t.Errorf("ignore case: got %s", got)
+	}
+}
+
+func TestConsoleBackend(t *testing.T) {
+
+	server := newJenkins(false)
+	defer server.Close()
+
+	// validation keeps quotes out of labels, still a value with quotes and backslashes
+	// must reach the script as is, not as Groovy code
+	label := `Team'A\" + System.exit(0) + '`
+	backend := &ConsoleBackend{Client: newClient(t, server, Auth{Username: server.Username, Password: server.Password})}
+	template := DockerTemplate{Label: label, Image: "example/slave"}
+	output, err := backend.Run(createDockerTemplateScript, map[string]string{"cloudName": "docker", "template": template.definition()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created, err := parseResult(createDockerTemplateScript, output); err != nil || !created {
+		t.Fatalf("created %v, %v", created, err)
+	}
+	if _, ok := server.Templates("docker")[label]; !ok {
+		t.Errorf("templates %v, want one for %q", server.Templates("docker"), label)
+	}
+
+	requests := strings.Join(server.Requests(), "\n")
+	if !strings.Contains(requests, "GET /crumbIssuer/api/json") || !strings.Contains(requests, "POST /scriptText") {
+		t.Errorf("requests:\n%s", requests)
+	}
+
+	// a script that throws is an error, not a result
+	if _, err := parseResult(createDockerTemplateScript, "groovy.lang.MissingPropertyException: No such property: cloud"); err == nil {
+		t.Error("exception output: expected an error")
+	}
+}
+
+func TestBind(t *testing.T) {
+
+	source := bind(removeDockerTemplateScript, map[string]string{"cloudName": "docker", "label": "it's\n"})
+	lines := strings.Split(source, "\n")
+
+	lastImport, firstDef := -1, -1
+	for i, line := range lines {
+		if strings.HasPrefix(line, "import ") {
+			lastImport = i
+		}
+		if strings.HasPrefix(line, "def ") && firstDef < 0 {
+			firstDef = i
+		}
+	}
+	if firstDef < lastImport {
+		t.Errorf("parameters defined before the imports:\n%s", source)
+	}
+	if !strings.Contains(source, `def label = 'it\'s\n'`) {
+		t.Errorf("label not escaped:\n%s", source)
+	}
+}
@@ -0,0 +1,461 @@
+// Package jenkinstest is a fake Jenkins master for testing code that talks to Jenkins without a live one
+package jenkinstest
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The CSRF crumb the Server issues until ExpireCrumb is called and the header it expects it in
+const (
+	Crumb      = "0123456789abcdef"
+	CrumbField = "Jenkins-Crumb"
+)
+
+// Build is the state of a job's last build
+type Build struct {
+	Number   int    `json:"number"`
+	Result   string `json:"result"`
+	Building bool   `json:"building"`
+}
+
+// Job is a job on the fake Jenkins
+type Job struct {
+	Name      string
+	Config    string
+	LastBuild *Build
+}
+
+// Server is a fake Jenkins master serving what dockhand uses: the Scriptler scripts that manage
+// docker slave templates, job creation and deletion, builds, crumbs and the API root.
+// It keeps the docker clouds and jobs in memory.
+type Server struct {
+	*httptest.Server
+
+	// Username and Password, when set, are required as basic auth on every request.
+	// APIToken is accepted instead of the password, Token as a bearer token instead of both.
+	Username string
+	Password string
+	APIToken string
+	Token    string
+	// Gzip compresses the responses to requests that accept it
+	Gzip bool
+	// CSRF requires the crumb on POST requests, like Jenkins with CSRF protection on
+	CSRF bool
+
+	mu       sync.Mutex
+	crumb    string
+	crumbs   int
+	clouds   map[string]map[string]string
+	jobs     map[string]*Job
+	failures map[string]int
+	requests []string
+
+	// definitions are the JSON definitions createDockerTemplate got, by cloud and label
+	definitions map[string]map[string]string
+}
+
+// NewServer starts a fake Jenkins without clouds or jobs, close it with Close
+func NewServer() *Server {
+
+	s := &Server{
+		crumb:       Crumb,
+		clouds:      map[string]map[string]string{},
+		definitions: map[string]map[string]string{},
+		jobs:        map[string]*Job{},
+		failures:    map[string]int{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// AddCloud adds a docker cloud with a slave template for each of labelStrings, which holds
+// the template's labels separated by whitespace like the label string in Jenkins does
+func (s *Server) AddCloud(name string, labelStrings ...string) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	templates := map[string]string{}
+	for _, labelString := range labelStrings {
+		templates[labelString] = strings.Fields(labelString)[0] + "-image"
+	}
+	s.clouds[name] = templates
+}
+
+// Templates returns the images of the slave templates in a cloud by label string, nil if there is no such cloud
+func (s *Server) Templates(cloud string) map[string]string {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	templates, ok := s.clouds[cloud]
+	if !ok {
+		return nil
+	}
+	copied := map[string]string{}
+	for label, image := range templates {
+		copied[label] = image
+	}
+	return copied
+}
+
+// Definition returns the definition a slave template was created with, decoded from the JSON
+// dockhand sent; nil for templates added with AddCloud or missing
+func (s *Server) Definition(cloud, label string) map[string]interface{} {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	definition, ok := s.definitions[cloud][label]
+	if !ok {
+		return nil
+	}
+	var decoded map[string]interface{}
+	json.Unmarshal([]byte(definition), &decoded)
+	return decoded
+}
+
+// AddJob adds a job with the given config.xml
+func (s *Server) AddJob(name, config string) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[name] = &Job{Name: name, Config: config}
+}
+
+// Job returns a copy of the named job
+func (s *Server) Job(name string) (Job, bool) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[name]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// SetLastBuild sets the last build of a job
+func (s *Server) SetLastBuild(name string, build Build) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job, ok := s.jobs[name]; ok {
+		job.LastBuild = &build
+	}
+}
+
+// Fail answers requests whose path starts with prefix with status, 0 to stop failing them
+func (s *Server) Fail(prefix string, status int) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if status == 0 {
+		delete(s.failures, prefix)
+		return
+	}
+	s.failures[prefix] = status
+}
+
+// ExpireCrumb makes the Server reject the crumb it issued so far and issue a new one,
+// like Jenkins does when the session the crumb belongs to ends
+func (s *Server) ExpireCrumb() {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.crumbs++
+	s.crumb = fmt.Sprintf("%s-%d", Crumb, s.crumbs)
+}
+
+// Requests returns the requests served so far as "METHOD /path?query"
+func (s *Server) Requests() []string {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+
+	if !s.authenticated(r) {
+		user, _, _ := r.BasicAuth()
+		http.Error(w, "Invalid password/token for user: "+user, http.StatusUnauthorized)
+		return
+	}
+	for prefix, status := range s.failures {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+	}
+	if r.Method == "POST" && s.CSRF && r.Header.Get(CrumbField) != s.crumb {
+		http.Error(w, "No valid crumb was included in the request", http.StatusForbidden)
+		return
+	}
+
+	status, body := s.route(r)
+	if status == http.StatusCreated {
+		// a build was queued
+		w.Header().Set("Location", s.URL+"/queue/item/1/")
+	}
+	w.Header().Set("X-Jenkins", "2.60.3")
+	if s.Gzip && strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(status)
+		compressed := gzip.NewWriter(w)
+		compressed.Write([]byte(body))
+		compressed.Close()
+		return
+	}
+	w.WriteHeader(status)
+	w.Write([]byte(body))
+}
+
+// authenticated checks the credentials of r against the ones the Server requires
+func (s *Server) authenticated(r *http.Request) bool {
+
+	if s.Token != "" && r.Header.Get("Authorization") == "Bearer "+s.Token {
+		return true
+	}
+	if s.Username == "" {
+		return s.Token == ""
+	}
+	user, password, ok := r.BasicAuth()
+	if !ok || user != s.Username {
+		return false
+	}
+	return password == s.Password || (s.APIToken != "" && password == s.APIToken)
+}
+
+// route answers a request with a status and body
+func (s *Server) route(r *http.Request) (int, string) {
+
+	path := r.URL.Path
+	switch {
+	case path == "/api/json":
+		return http.StatusOK, toJSON(map[string]interface{}{"mode": "NORMAL", "nodeDescription": "the master Jenkins node", "jobs": s.jobList()})
+	case path == "/crumbIssuer/api/json":
+		return http.StatusOK, toJSON(map[string]string{"crumb": s.crumb, "crumbRequestField": CrumbField})
+	case strings.HasPrefix(path, "/scriptler/run/"):
+		query := r.URL.Query()
+		params := map[string]string{}
+		for name := range query {
+			params[name] = query.Get(name)
+		}
+		return s.runScript(strings.TrimPrefix(path, "/scriptler/run/"), params)
+	case path == "/scriptText" && r.Method == "POST":
+		return s.runConsoleScript(r.FormValue("script"))
+	case path == "/createItem" && r.Method == "POST":
+		return s.createJob(r)
+	case strings.HasPrefix(path, "/job/"):
+		return s.jobRequest(r)
+	}
+	return http.StatusNotFound, "Not found: " + path
+}
+
+func (s *Server) jobList() []map[string]string {
+
+	names := make([]string, 0, len(s.jobs))
+	for name := range s.jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	list := []map[string]string{}
+	for _, name := range names {
+		list = append(list, map[string]string{"name": name, "url": s.URL + "/job/" + name + "/"})
+	}
+	return list
+}
+
+// scriptName finds the name of a dockhand script in the comment it starts with
+var scriptName = regexp.MustCompile(`(?m)^// (\w+\.groovy) - `)
+
+// scriptParam matches the definitions of the parameters bound into a script
+var scriptParam = regexp.MustCompile(`(?m)^def (\w+) = '((?:[^'\\]|\\.)*)'$`)
+
+// runConsoleScript runs a dockhand script sent to the script console, telling which it is by its name
+func (s *Server) runConsoleScript(source string) (int, string) {
+
+	name := scriptName.FindStringSubmatch(source)
+	if name == nil {
+		// Jenkins prints the exception a script throws, the fake cannot run any other Groovy
+		return http.StatusOK, "groovy.lang.MissingPropertyException: the fake Jenkins only runs dockhand's scripts"
+	}
+	params := map[string]string{}
+	for _, match := range scriptParam.FindAllStringSubmatch(source, -1) {
+		params[match[1]] = unquote(match[2])
+	}
+	return s.runScript(name[1], params)
+}
+
+// unquote undoes the escaping of a single quoted Groovy string
+func unquote(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\'`, `'`, `\n`, "\n", `\r`, "\r").Replace(s)
+}
+
+// runScript runs one of the scripts dockhand uses against the clouds in memory
+func (s *Server) runScript(script string, params map[string]string) (int, string) {
+
+	cloud, label := params["cloudName"], params["label"]
+	templates, ok := s.clouds[cloud]
+
+	switch script {
+	case "getLabels.groovy":
+		labels := []string{}
+		for labelString := range templates {
+			labels = append(labels, strings.Fields(labelString)...)
+		}
+		sort.Strings(labels)
+		return http.StatusOK, toJSON(labels)
+	case "getTemplateImage.groovy":
+		labelString, exists := findTemplate(templates, label)
+		if !exists {
+			return http.StatusOK, "null"
+		}
+		return http.StatusOK, toJSON(templates[labelString])
+	case "createDockerTemplate.groovy":
+		var spec struct {
+			Label string `json:"label"`
+			Image string `json:"image"`
+		}
+		if err := json.Unmarshal([]byte(params["template"]), &spec); err != nil {
+			// what JsonSlurper throws
+			return http.StatusOK, "groovy.json.JsonException: " + err.Error()
+		}
+		if !ok || spec.Label == "" {
+			return http.StatusOK, "false"
+		}
+		if _, exists := findTemplate(templates, spec.Label); exists {
+			return http.StatusOK, "false"
+		}
+		templates[spec.Label] = spec.Image
+		if s.definitions[cloud] == nil {
+			s.definitions[cloud] = map[string]string{}
+		}
+		s.definitions[cloud][spec.Label] = params["template"]
+		return http.StatusOK, "true"
+	case "removeDockerTemplate.groovy":
+		labelString, exists := findTemplate(templates, label)
+		if !ok || !exists {
+			return http.StatusOK, "false"
+		}
+		delete(templates, labelString)
+		delete(s.definitions[cloud], labelString)
+		return http.StatusOK, "true"
+	}
+	return http.StatusNotFound, "No script named " + script
+}
+
+// findTemplate returns the label string of the template with label among its labels,
+// the first one in sorted order when several have it
+func findTemplate(templates map[string]string, label string) (string, bool) {
+
+	labelStrings := make([]string, 0, len(templates))
+	for labelString := range templates {
+		labelStrings = append(labelStrings, labelString)
+	}
+	sort.Strings(labelStrings)
+	for _, labelString := range labelStrings {
+		for _, l := range strings.Fields(labelString) {
+			if l == label {
+				return labelString, true
+			}
+		}
+	}
+	return "", false
+}
+
+func (s *Server) createJob(r *http.Request) (int, string) {
+
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		return http.StatusBadRequest, "Query parameter 'name' is required"
+	}
+	if _, exists := s.jobs[name]; exists {
+		return http.StatusBadRequest, "A job already exists with the name '" + name + "'"
+	}
+	config, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return http.StatusBadRequest, err.Error()
+	}
+	s.jobs[name] = &Job{Name: name, Config: string(config)}
+	return http.StatusOK, ""
+}
+
+// jobRequest serves /job/<name>/... : the job, its config, its last build, builds and deletion
+func (s *Server) jobRequest(r *http.Request) (int, string) {
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/job/"), "/", 2)
+	job, ok := s.jobs[parts[0]]
+	if !ok {
+		return http.StatusNotFound, "No job named " + parts[0]
+	}
+	rest := ""
+	if len(parts) == 2 {
+		rest = strings.TrimSuffix(parts[1], "/")
+	}
+
+	switch {
+	case rest == "api/json":
+		info := map[string]interface{}{"name": job.Name, "url": s.URL + "/job/" + job.Name + "/", "buildable": true, "color": "notbuilt"}
+		if job.LastBuild != nil {
+			info["lastBuild"] = map[string]interface{}{"number": job.LastBuild.Number, "url": fmt.Sprintf("%s/job/%s/%d/", s.URL, job.Name, job.LastBuild.Number)}
+			info["color"] = color(*job.LastBuild)
+		}
+		return http.StatusOK, toJSON(info)
+	case rest == "config.xml" && r.Method == "GET":
+		return http.StatusOK, job.Config
+	case rest == "lastBuild/api/json":
+		if job.LastBuild == nil {
+			return http.StatusNotFound, "No builds of " + job.Name
+		}
+		return http.StatusOK, toJSON(job.LastBuild)
+	case (rest == "build" || rest == "buildWithParameters") && r.Method == "POST":
+		number := 1
+		if job.LastBuild != nil {
+			number = job.LastBuild.Number + 1
+		}
+		job.LastBuild = &Build{Number: number, Building: true}
+		return http.StatusCreated, ""
+	case rest == "doDelete" && r.Method == "POST":
+		delete(s.jobs, job.Name)
+		return http.StatusOK, ""
+	}
+	return http.StatusNotFound, "Not found: " + r.URL.Path
+}
+
+// color is the ball color Jenkins shows for a job whose last build is build
+func color(build Build) string {
+
+	c := "notbuilt"
+	switch build.Result {
+	case "SUCCESS":
+		c = "blue"
+	case "FAILURE":
+		c = "red"
+	case "UNSTABLE":
+		c = "yellow"
+	case "ABORTED":
+		c = "aborted"
+	}
+	if build.Building {
+		c += "_anime"
+	}
+	return c
+}
+
+func toJSON(v interface{}) string {
+
+	encoded, _ := json.Marshal(v)
+	return string(encoded)
+}
@@ -0,0 +1,59 @@
+package jenkinstest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestJobs(t *testing.T) {
+
+	server := NewServer()
+	defer server.Close()
+	server.CSRF = true
+
+	post := func(path, body string, crumb bool) int {
+		request, _ := http.NewRequest("POST", server.URL+path, strings.NewReader(body))
+		if crumb {
+			request.Header.Set(CrumbField, Crumb)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		return response.StatusCode
+	}
+
+	if status := post("/createItem?name=TeamA", "<project/>", false); status != http.StatusForbidden {
+		t.Errorf("create without a crumb: status %d", status)
+	}
+	if status := post("/createItem?name=TeamA", "<project/>", true); status != http.StatusOK {
+		t.Errorf("create: status %d", status)
+	}
+	if job, ok := server.Job("TeamA"); !ok || job.Config != "<project/>" {
+		t.Errorf("job %+v, %v", job, ok)
+	}
+	if status := post("/job/TeamA/build", "", true); status != http.StatusCreated {
+		t.Errorf("build: status %d", status)
+	}
+
+	response, err := http.Get(server.URL + "/job/TeamA/lastBuild/api/json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var build Build
+	json.NewDecoder(response.Body).Decode(&build)
+	response.Body.Close()
+	if build.Number != 1 || !build.Building {
+		t.Errorf("last build %+v", build)
+	}
+
+	if status := post("/job/TeamA/doDelete", "", true); status != http.StatusOK {
+		t.Errorf("delete: status %d", status)
+	}
+	if _, ok := server.Job("TeamA"); ok {
+		t.Error("job still there after deletion")
+	}
+}
@@ -0,0 +1,29 @@
+package jenkins
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// JobExists reports whether Jenkins has a job called name
+func JobExists(client *Client, name string) (bool, error) {
+
+	jobURL, err := client.endpoint("/job/"+name+"/api/json", nil)
+	if err != nil {
+		return false, err
+	}
+	response, err := client.HTTP.Get(jobURL)
+	if err != nil {
+		return false, err
+	}
+	response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, errors.New("ERROR: Response code: " + strconv.Itoa(response.StatusCode) + " from " + jobURL)
+}
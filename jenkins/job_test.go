@@ -0,0 +1,26 @@
+package jenkins
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestJobExists(t *testing.T) {
+
+	server := newJenkins(false)
+	defer server.Close()
+	server.AddJob("TeamA_JOB", "<project/>")
+	client := newClient(t, server, Auth{Username: server.Username, Password: server.Password})
+
+	if exists, err := JobExists(client, "TeamA_JOB"); err != nil || !exists {
+		t.Errorf("existing job: %v, %v", exists, err)
+	}
+	if exists, err := JobExists(client, "TeamB_JOB"); err != nil || exists {
+		t.Errorf("missing job: %v, %v", exists, err)
+	}
+
+	server.Fail("/job/", http.StatusInternalServerError)
+	if _, err := JobExists(client, "TeamA_JOB"); err == nil {
+		t.Error("server error: expected an error")
+	}
+}
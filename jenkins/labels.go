@@ -0,0 +1,67 @@
+package jenkins
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// LabelMatch says when two labels count as the same
+type LabelMatch string
+
+// Label match policies: Jenkins itself tells labels apart by case, MatchIgnoreCase also
+// refuses labels that only differ from an existing one in case
+const (
+	MatchExact      LabelMatch = "exact"
+	MatchIgnoreCase LabelMatch = "ignoreCase"
+)
+
+// Equal reports whether labels a and b are the same under the policy, MatchExact when it is empty
+func (m LabelMatch) Equal(a, b string) bool {
+
+	if m == MatchIgnoreCase {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// parseLabels reads the output of getLabels.groovy, a JSON list of the labels in the cloud.
+// An entry that is a whole label string, as an older getLabels.groovy installed in Scriptler
+// prints, is split into its labels.
+func parseLabels(body string) ([]string, error) {
+
+	body = strings.TrimSpace(body)
+	var printed []string
+	if err := json.Unmarshal([]byte(body), &printed); err != nil {
+		if len(body) > 200 {
+			body = body[:200] + "..."
+		}
+		return nil, errors.New("getLabels.groovy should print a JSON list of labels, it printed: " + body)
+	}
+	labels := []string{}
+	for _, labelString := range printed {
+		labels = append(labels, strings.Fields(labelString)...)
+	}
+	return labels, nil
+}
+
+// SuggestLabel returns a label like label, with a number added, that none of the existing labels matches
+func SuggestLabel(label string, existing []string, match LabelMatch) string {
+
+	taken := func(candidate string) bool {
+		for _, e := range existing {
+			if match.Equal(e, candidate) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for n := 2; ; n++ {
+		candidate := label + strconv.Itoa(n)
+		if !taken(candidate) {
+			return candidate
+		}
+	}
+}
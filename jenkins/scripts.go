@@ -0,0 +1,216 @@
+package jenkins
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Script is one of the Groovy scripts dockhand runs on the Jenkins master to manage the docker
+// slave templates. Params are the variables the script expects to be bound.
+type Script struct {
+	// Name is the script's ID in Scriptler, without .groovy
+	Name   string
+	Params []string
+	Source string
+}
+
+// The scripts dockhand ships. They print their result: a JSON list of labels, an image as JSON, or true or false.
+var (
+	getLabelsScript = Script{
+		Name:   "getLabels",
+		Params: []string{"cloudName"},
+		Source: `// getLabels.groovy - prints the labels of the docker slave templates in cloudName as a JSON list,
+// a template's label string holds its labels separated by whitespace
+import com.nirima.jenkins.plugins.docker.DockerCloud
+import groovy.json.JsonOutput
+import jenkins.model.Jenkins
+
+def cloud = Jenkins.instance.clouds.getByName(cloudName)
+if (!(cloud instanceof DockerCloud)) {
+    println JsonOutput.toJson([])
+    return
+}
+println JsonOutput.toJson(cloud.templates.collectMany { it.labelString.split(/\s+/) as List })
+`,
+	}
+
+	getTemplateImageScript = Script{
+		Name:   "getTemplateImage",
+		Params: []string{"cloudName", "label"},
+		Source: `// getTemplateImage.groovy - prints the image of the slave template with label among its labels
+// in cloudName as JSON, null if there is none
+import com.nirima.jenkins.plugins.docker.DockerCloud
+import groovy.json.JsonOutput
+import jenkins.model.Jenkins
+
+def cloud = Jenkins.instance.clouds.getByName(cloudName)
+def template = cloud instanceof DockerCloud ? cloud.templates.find { it.labelString.split(/\s+/).contains(label) } : null
+println JsonOutput.toJson(template?.image)
+`,
+	}
+
+	createDockerTemplateScript = Script{
+		Name:   "createDockerTemplate",
+		Params: []string{"cloudName", "template"},
+		Source: `// createDockerTemplate.groovy - adds the slave template described by the JSON template to cloudName,
+// prints false if there is no such docker cloud or the label is taken
+import com.nirima.jenkins.plugins.docker.DockerCloud
+import com.nirima.jenkins.plugins.docker.DockerImagePullStrategy
+import com.nirima.jenkins.plugins.docker.DockerTemplate
+import com.nirima.jenkins.plugins.docker.DockerTemplateBase
+import com.nirima.jenkins.plugins.docker.strategy.DockerOnceRetentionStrategy
+import groovy.json.JsonSlurper
+import hudson.plugins.sshslaves.verifiers.NonVerifyingKeyVerificationStrategy
+import hudson.slaves.JNLPLauncher
+import io.jenkins.docker.connector.DockerComputerAttachConnector
+import io.jenkins.docker.connector.DockerComputerJNLPConnector
+import io.jenkins.docker.connector.DockerComputerSSHConnector
+import jenkins.model.Jenkins
+
+def spec = new JsonSlurper().parseText(template)
+def cloud = Jenkins.instance.clouds.getByName(cloudName)
+if (!(cloud instanceof DockerCloud) || cloud.templates.any { it.labelString.split(/\s+/).contains(spec.label) }) {
+    println false
+    return
+}
+
+def base = new DockerTemplateBase(spec.image)
+if (spec.memoryLimit) base.memoryLimit = spec.memoryLimit
+if (spec.memorySwap) base.memorySwap = spec.memorySwap
+if (spec.cpuShares) base.cpuShares = spec.cpuShares
+if (spec.volumes) base.volumesString = spec.volumes.join('\n')
+if (spec.env) base.environmentsString = spec.env.join('\n')
+if (spec.extraHosts) base.extraHostsString = spec.extraHosts.join('\n')
+if (spec.network) base.network = spec.network
+if (spec.registryCredentialsID) base.pullCredentialsId = spec.registryCredentialsID
+
+def connector
+switch (spec.launch) {
+    case 'ssh':
+        def key = new DockerComputerSSHConnector.ManuallyConfiguredSSHKey(spec.sshCredentialsID, new NonVerifyingKeyVerificationStrategy())
+        connector = new DockerComputerSSHConnector(key)
+        break
+    case 'jnlp':
+        connector = new DockerComputerJNLPConnector(new JNLPLauncher())
+        break
+    default:
+        connector = new DockerComputerAttachConnector()
+}
+
+def slave = new DockerTemplate(base, connector, spec.label, spec.remoteFS ?: '/home/jenkins', spec.instanceCap ? spec.instanceCap.toString() : '')
+if (spec.pullStrategy) slave.pullStrategy = DockerImagePullStrategy.valueOf('PULL_' + spec.pullStrategy.toUpperCase())
+if (spec.idleMinutes) slave.retentionStrategy = new DockerOnceRetentionStrategy(spec.idleMinutes)
+cloud.addTemplate(slave)
+Jenkins.instance.save()
+println true
+`,
+	}
+
+	removeDockerTemplateScript = Script{
+		Name:   "removeDockerTemplate",
+		Params: []string{"cloudName", "label"},
+		Source: `// removeDockerTemplate.groovy - removes the slave template with label among its labels from cloudName,
+// prints false if there is none
+import com.nirima.jenkins.plugins.docker.DockerCloud
+import jenkins.model.Jenkins
+
+def cloud = Jenkins.instance.clouds.getByName(cloudName)
+def template = cloud instanceof DockerCloud ? cloud.templates.find { it.labelString.split(/\s+/).contains(label) } : null
+if (template == null) {
+    println false
+    return
+}
+cloud.removeTemplate(template)
+Jenkins.instance.save()
+println true
+`,
+	}
+)
+
+// Scripts are the scripts dockhand runs, to install in Scriptler when the Scriptler backend is used
+var Scripts = []Script{getLabelsScript, getTemplateImageScript, createDockerTemplateScript, removeDockerTemplateScript}
+
+// Backend runs dockhand's scripts on a Jenkins master and returns what they print
+type Backend interface {
+	Run(script Script, params map[string]string) (string, error)
+}
+
+// Backends
+const (
+	// BackendConsole sends the scripts to the script console, it needs nothing installed on Jenkins
+	BackendConsole = "console"
+	// BackendScriptler runs the scripts installed in the Scriptler plugin
+	BackendScriptler = "scriptler"
+)
+
+// NewBackend returns the backend named kind, BackendConsole when it is empty, sending the scripts through client
+func NewBackend(kind string, client *Client) (Backend, error) {
+
+	switch kind {
+	case "", BackendConsole:
+		return &ConsoleBackend{Client: client}, nil
+	case BackendScriptler:
+		return &ScriptlerBackend{Client: client}, nil
+	}
+	return nil, errors.New("unknown Jenkins script backend " + kind + ", use " + BackendConsole + " or " + BackendScriptler)
+}
+
+// bind defines each of the script's parameters right after its imports, which have to come first
+func bind(script Script, params map[string]string) string {
+
+	lines := strings.Split(script.Source, "\n")
+	i := 0
+	for j, line := range lines {
+		if strings.HasPrefix(line, "import ") {
+			i = j + 1
+		}
+	}
+
+	var source []string
+	source = append(source, lines[:i]...)
+	for _, name := range script.Params {
+		source = append(source, "def "+name+" = "+groovyString(params[name]))
+	}
+	source = append(source, lines[i:]...)
+	return strings.Join(source, "\n")
+}
+
+// groovyString quotes s as a single quoted Groovy string literal
+func groovyString(s string) string {
+
+	replacer := strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\r", `\r`)
+	return "'" + replacer.Replace(s) + "'"
+}
+
+// parseResult reads the true or false a script printed as its result
+func parseResult(script Script, output string) (bool, error) {
+
+	switch strings.TrimSpace(output) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	if len(output) > 500 {
+		output = output[:500] + "..."
+	}
+	return false, errors.New(script.Name + " failed: " + strings.TrimSpace(output))
+}
+
+// parseImage reads the image getTemplateImage.groovy printed as JSON, false when it printed null
+func parseImage(output string) (string, bool, error) {
+
+	output = strings.TrimSpace(output)
+	var image *string
+	if err := json.Unmarshal([]byte(output), &image); err != nil {
+		if len(output) > 200 {
+			output = output[:200] + "..."
+		}
+		return "", false, errors.New("getTemplateImage.groovy should print the image as JSON, it printed: " + output)
+	}
+	if image == nil {
+		return "", false, nil
+	}
+	return *image, true, nil
+}
@@ -0,0 +1,116 @@
+package jenkins
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// How Jenkins connects to a docker slave
+const (
+	LaunchAttach = "attach"
+	LaunchSSH    = "ssh"
+	LaunchJNLP   = "jnlp"
+)
+
+// When the docker cloud pulls the image of a slave
+const (
+	PullAlways = "always"
+	PullLatest = "latest"
+	PullNever  = "never"
+)
+
+// DockerTemplate is a docker slave template, with the settings of the template form of the
+// docker plugin. The zero value of a field keeps the plugin's default.
+type DockerTemplate struct {
+	Label string `json:"label"`
+	Image string `json:"image"`
+	// RemoteFS is the slave's root directory, /home/jenkins when empty
+	RemoteFS string `json:"remoteFS,omitempty"`
+	// InstanceCap is how many slaves of the template may run at once, 0 for no limit
+	InstanceCap int `json:"instanceCap,omitempty"`
+	// Launch is LaunchAttach, LaunchSSH or LaunchJNLP, LaunchAttach when empty.
+	// SSHCredentialsID is the Jenkins credentials of the key an ssh launch logs in with.
+	Launch           string `json:"launch,omitempty"`
+	SSHCredentialsID string `json:"sshCredentialsID,omitempty"`
+	// PullStrategy is PullAlways, PullLatest or PullNever
+	PullStrategy string `json:"pullStrategy,omitempty"`
+	// MemoryLimit and MemorySwap are in MB, MemorySwap -1 for unlimited swap.
+	// CPUShares is the container's relative CPU weight.
+	MemoryLimit int `json:"memoryLimit,omitempty"`
+	MemorySwap  int `json:"memorySwap,omitempty"`
+	CPUShares   int `json:"cpuShares,omitempty"`
+	// Volumes are [host:]container[:options] mounts, Env KEY=VALUE pairs and ExtraHosts host:IP entries
+	Volumes    []string `json:"volumes,omitempty"`
+	Env        []string `json:"env,omitempty"`
+	ExtraHosts []string `json:"extraHosts,omitempty"`
+	// RegistryCredentialsID is the Jenkins credentials the image is pulled with
+	RegistryCredentialsID string `json:"registryCredentialsID,omitempty"`
+	// IdleMinutes is how long a slave may wait for a build before it is removed
+	IdleMinutes int `json:"idleMinutes,omitempty"`
+	// Network is the docker network the slave runs on, the plugin's default (bridge) when empty
+	Network string `json:"network,omitempty"`
+}
+
+// Validate checks the template against what Jenkins and the docker plugin accept.
+// The error is a *ValidationError listing every invalid field.
+func (t DockerTemplate) Validate() error {
+	return validate(t.checks()...)
+}
+
+// checks runs the checks of every field, nil entries are the fields that passed
+func (t DockerTemplate) checks() []*FieldError {
+
+	checks := []*FieldError{checkLabel(t.Label), checkImage(t.Image)}
+	check := func(failed bool, field string, value string, problem string) {
+		if failed {
+			checks = append(checks, &FieldError{field, value, problem})
+		}
+	}
+
+	check(t.RemoteFS != "" && !strings.HasPrefix(t.RemoteFS, "/"), "remoteFS", t.RemoteFS, "must be an absolute path")
+	check(t.Launch != "" && t.Launch != LaunchAttach && t.Launch != LaunchSSH && t.Launch != LaunchJNLP,
+		"launch", t.Launch, "must be "+LaunchAttach+", "+LaunchSSH+" or "+LaunchJNLP)
+	check(t.Launch == LaunchSSH && t.SSHCredentialsID == "", "sshCredentialsID", "", "is required to launch slaves over ssh")
+	check(t.PullStrategy != "" && t.PullStrategy != PullAlways && t.PullStrategy != PullLatest && t.PullStrategy != PullNever,
+		"pullStrategy", t.PullStrategy, "must be "+PullAlways+", "+PullLatest+" or "+PullNever)
+
+	numbers := []struct {
+		field string
+		value int
+	}{
+		{"instanceCap", t.InstanceCap},
+		{"memoryLimit", t.MemoryLimit},
+		{"cpuShares", t.CPUShares},
+		{"idleMinutes", t.IdleMinutes},
+	}
+	for _, n := range numbers {
+		check(n.value < 0, n.field, strconv.Itoa(n.value), "must not be negative")
+	}
+	check(t.MemorySwap < -1 || (t.MemorySwap > 0 && t.MemorySwap < t.MemoryLimit),
+		"memorySwap", strconv.Itoa(t.MemorySwap), "must be -1 for unlimited or at least memoryLimit")
+
+	// the plugin keeps the lists as text with an entry per line
+	for _, volume := range t.Volumes {
+		check(strings.TrimSpace(volume) == "" || strings.ContainsAny(volume, "\r\n"), "volumes", volume, "must be one [host:]container[:options] mount")
+	}
+	for _, env := range t.Env {
+		i := strings.Index(env, "=")
+		check(i <= 0 || strings.ContainsAny(env, "\r\n"), "env", env, "must be one KEY=VALUE pair")
+	}
+	for _, host := range t.ExtraHosts {
+		i := strings.Index(host, ":")
+		check(i <= 0 || net.ParseIP(host[i+1:]) == nil, "extraHosts", host, "must be host:IP")
+	}
+	check(strings.IndexFunc(t.Network, unicode.IsSpace) >= 0, "network", t.Network, "must not contain whitespace")
+	return checks
+}
+
+// definition is the template as the JSON createDockerTemplate.groovy reads
+func (t DockerTemplate) definition() string {
+
+	encoded, _ := json.Marshal(t)
+	return string(encoded)
+}
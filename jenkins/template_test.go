@@ -0,0 +1,110 @@
+package jenkins
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDockerTemplateValidate(t *testing.T) {
+
+	valid := DockerTemplate{Label: "TeamA", Image: "example/slave:1.0"}
+
+	tests := []struct {
+		name    string
+		mutate  func(*DockerTemplate)
+		invalid string
+	}{
+		{name: "minimal"},
+		{name: "everything", mutate: func(d *DockerTemplate) {
+			*d = DockerTemplate{
+				Label: "TeamA", Image: "example/slave:1.0", RemoteFS: "/home/jenkins", InstanceCap: 4,
+				Launch: LaunchSSH, SSHCredentialsID: "slave-key", PullStrategy: PullAlways,
+				MemoryLimit: 2048, MemorySwap: -1, CPUShares: 512,
+				Volumes: []string{"/var/run/docker.sock:/var/run/docker.sock", "/cache"}, Env: []string{"TEAM=A", "EMPTY="},
+				ExtraHosts: []string{"nexus:10.0.0.5", "ipv6:fe80::1"}, RegistryCredentialsID: "registry", IdleMinutes: 10, Network: "ci",
+			}
+		}},
+		{name: "relative remote FS", mutate: func(d *DockerTemplate) { d.RemoteFS = "home/jenkins" }, invalid: "remoteFS"},
+		{name: "unknown launch", mutate: func(d *DockerTemplate) { d.Launch = "telnet" }, invalid: "launch"},
+		{name: "ssh without key", mutate: func(d *DockerTemplate) { d.Launch = LaunchSSH }, invalid: "sshCredentialsID"},
+		{name: "unknown pull strategy", mutate: func(d *DockerTemplate) { d.PullStrategy = "PULL_LATEST" }, invalid: "pullStrategy"},
+		{name: "negative cap", mutate: func(d *DockerTemplate) { d.InstanceCap = -1 }, invalid: "instanceCap"},
+		{name: "swap below memory", mutate: func(d *DockerTemplate) { d.MemoryLimit, d.MemorySwap = 1024, 512 }, invalid: "memorySwap"},
+		{name: "env without value", mutate: func(d *DockerTemplate) { d.Env = []string{"TEAM"} }, invalid: "env"},
+		{name: "two env pairs in one", mutate: func(d *DockerTemplate) { d.Env = []string{"A=1\nB=2"} }, invalid: "env"},
+		{name: "empty volume", mutate: func(d *DockerTemplate) { d.Volumes = []string{" "} }, invalid: "volumes"},
+		{name: "extra host without IP", mutate: func(d *DockerTemplate) { d.ExtraHosts = []string{"nexus:nexus.example.com"} }, invalid: "extraHosts"},
+		{name: "network with space", mutate: func(d *DockerTemplate) { d.Network = "ci net" }, invalid: "network"},
+		{name: "bad label and image", mutate: func(d *DockerTemplate) { d.Label, d.Image = "a b", "Example" }, invalid: "label,image"},
+	}
+
+	for _, test := range tests {
+		template := valid
+		if test.mutate != nil {
+			test.mutate(&template)
+		}
+		err := template.Validate()
+		if test.invalid == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", test.name, err)
+			}
+			continue
+		}
+		validationErr, ok := err.(*ValidationError)
+		if !ok {
+			t.Errorf("%s: got %v, want a *ValidationError", test.name, err)
+			continue
+		}
+		var fields []string
+		for _, field := range validationErr.Fields {
+			fields = append(fields, field.Field)
+		}
+		if strings.Join(fields, ",") != test.invalid {
+			t.Errorf("%s: invalid fields %v, want %s", test.name, fields, test.invalid)
+		}
+	}
+}
+
+func TestCreateDockerTemplateDefinition(t *testing.T) {
+
+	template := DockerTemplate{
+		Label:                 "TeamB",
+		Image:                 "registry.example.com/team/slave@sha256:" + strings.Repeat("ab", 32),
+		InstanceCap:           3,
+		Launch:                LaunchJNLP,
+		PullStrategy:          PullNever,
+		MemoryLimit:           1024,
+		Volumes:               []string{"/var/run/docker.sock:/var/run/docker.sock"},
+		Env:                   []string{"JAVA_OPTS=-Xmx512m -Dfile.encoding=UTF-8", "QUOTE='x'"},
+		ExtraHosts:            []string{"nexus:10.0.0.5"},
+		RegistryCredentialsID: "registry",
+		IdleMinutes:           5,
+		Network:               "ci",
+	}
+
+	for _, kind := range backends {
+		server := newJenkins(false)
+		created, err := CreateDockerTemplate(newBackend(t, kind, server, server.Password), "docker", template)
+		definition := server.Definition("docker", "TeamB")
+		server.Close()
+		if err != nil || !created {
+			t.Errorf("%s: created %v, %v", kind, created, err)
+			continue
+		}
+
+		if definition["image"] != template.Image || definition["launch"] != "jnlp" || definition["pullStrategy"] != "never" {
+			t.Errorf("%s: definition %v", kind, definition)
+		}
+		if definition["instanceCap"] != 3.0 || definition["memoryLimit"] != 1024.0 || definition["idleMinutes"] != 5.0 {
+			t.Errorf("%s: numbers in definition %v", kind, definition)
+		}
+		env, _ := definition["env"].([]interface{})
+		if len(env) != 2 || env[0] != template.Env[0] || env[1] != template.Env[1] {
+			t.Errorf("%s: env %v", kind, definition["env"])
+		}
+		// the fields left empty keep the plugin's defaults
+		if _, ok := definition["remoteFS"]; ok {
+			t.Errorf("%s: empty remoteFS sent: %v", kind, definition)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package jenkins
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// FieldError is a value dockhand would send to Jenkins that Jenkins rejects or reads as something else
+type FieldError struct {
+	Field   string
+	Value   string
+	Problem string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + " " + strconv.Quote(e.Value) + " " + e.Problem
+}
+
+// ValidationError lists the invalid fields of a request, nothing is sent to Jenkins when there are any
+type ValidationError struct {
+	Fields []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+
+	problems := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		problems[i] = field.Error()
+	}
+	return "invalid Jenkins request: " + strings.Join(problems, "; ")
+}
+
+// ValidateTemplate checks the cloud name, label and image of a docker slave template against
+// the Jenkins and Docker naming rules, skipping the image when it is empty.
+// The error is a *ValidationError listing every invalid field.
+func ValidateTemplate(cloudName string, label string, image string) error {
+
+	checks := []*FieldError{checkCloudName(cloudName), checkLabel(label)}
+	if image != "" {
+		checks = append(checks, checkImage(image))
+	}
+	return validate(checks...)
+}
+
+// validate collects the failed checks into a *ValidationError, nil when all passed
+func validate(checks ...*FieldError) error {
+
+	var failed []*FieldError
+	for _, check := range checks {
+		if check != nil {
+			failed = append(failed, check)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: failed}
+}
+
+// unsafeNameChars are the characters Jenkins refuses in the names of items, nodes and clouds
+const unsafeNameChars = `?*/\%!@#$^&|<>[]:;`
+
+// checkName applies the rules Jenkins has for every name
+func checkName(field string, name string) *FieldError {
+
+	switch {
+	case strings.TrimSpace(name) == "":
+		return &FieldError{field, name, "is empty"}
+	case name == "." || name == "..":
+		return &FieldError{field, name, "is not allowed by Jenkins"}
+	case strings.ContainsAny(name, unsafeNameChars):
+		return &FieldError{field, name, "must not contain any of " + unsafeNameChars}
+	case strings.IndexFunc(name, unicode.IsControl) >= 0:
+		return &FieldError{field, name, "must not contain control characters"}
+	}
+	return nil
+}
+
+func checkCloudName(name string) *FieldError {
+
+	if err := checkName("cloudName", name); err != nil {
+		return err
+	}
+	if strings.TrimSpace(name) != name {
+		return &FieldError{"cloudName", name, "must not start or end with whitespace"}
+	}
+	return nil
+}
+
+// checkLabel also refuses whitespace, which separates the labels of a template, and the
+// characters of label expressions
+func checkLabel(label string) *FieldError {
+
+	if err := checkName("label", label); err != nil {
+		return err
+	}
+	if strings.IndexFunc(label, unicode.IsSpace) >= 0 {
+		return &FieldError{"label", label, "must not contain whitespace"}
+	}
+	if strings.ContainsAny(label, `()"'=`) {
+		return &FieldError{"label", label, `must not contain any of ()"'=`}
+	}
+	return nil
+}
+
+// imageReference is the grammar of a Docker image reference: [registry[:port]/]name[:tag][@digest]
+var imageReference = func() *regexp.Regexp {
+
+	component := `[a-z0-9]+(?:(?:[._]|__|-*)[a-z0-9]+)*`
+	hostPart := `(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])`
+	domain := hostPart + `(?:\.` + hostPart + `)*(?::[0-9]+)?`
+	name := `(?:` + domain + `/)?` + component + `(?:/` + component + `)*`
+	tag := `:[\w][\w.-]{0,127}`
+	digest := `@[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,}`
+	return regexp.MustCompile(`^(` + name + `)(?:` + tag + `)?(?:` + digest + `)?$`)
+}()
+
+func checkImage(image string) *FieldError {
+
+	match := imageReference.FindStringSubmatch(image)
+	if match == nil {
+		return &FieldError{"image", image, "is not a Docker image reference ([registry/]name[:tag][@digest], lower case name)"}
+	}
+	if len(match[1]) > 255 {
+		return &FieldError{"image", image, "has a name longer than 255 characters"}
+	}
+	return nil
+}
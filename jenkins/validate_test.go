@@ -0,0 +1,79 @@
+package jenkins
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateTemplate(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		cloud   string
+		label   string
+		image   string
+		invalid []string
+	}{
+		{name: "valid", cloud: "docker", label: "TeamA_DotNetCore2.3", image: "registry.example.com:5000/team/slave:1.0"},
+		{name: "digest", cloud: "Azure Jenkins", label: "TeamA", image: "team/slave@sha256:" + strings.Repeat("ab", 32)},
+		{name: "tag and digest", cloud: "docker", label: "TeamA", image: "team/slave:1.0@sha256:" + strings.Repeat("ab", 32)},
+		{name: "no image", cloud: "docker", label: "TeamA"},
+		{name: "label with space", cloud: "docker", label: "Team A", image: "team/slave", invalid: []string{"label"}},
+		{name: "label expression", cloud: "docker", label: "TeamA&&linux", image: "team/slave", invalid: []string{"label"}},
+		{name: "label with parens", cloud: "docker", label: "(TeamA)", image: "team/slave", invalid: []string{"label"}},
+		{name: "empty label", cloud: "docker", label: "", image: "team/slave", invalid: []string{"label"}},
+		{name: "cloud with slash", cloud: "docker/azure", label: "TeamA", invalid: []string{"cloudName"}},
+		{name: "cloud with trailing space", cloud: "docker ", label: "TeamA", invalid: []string{"cloudName"}},
+		{name: "upper case image", cloud: "docker", label: "TeamA", image: "Team/Slave", invalid: []string{"image"}},
+		{name: "image with query", cloud: "docker", label: "TeamA", image: "team/slave:1.0&label=x", invalid: []string{"image"}},
+		{name: "everything", cloud: "", label: "a b", image: "team/slave:", invalid: []string{"cloudName", "label", "image"}},
+	}
+
+	for _, test := range tests {
+		err := ValidateTemplate(test.cloud, test.label, test.image)
+		if test.invalid == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", test.name, err)
+			}
+			continue
+		}
+		validationErr, ok := err.(*ValidationError)
+		if !ok {
+			t.Errorf("%s: got %v, want a *ValidationError", test.name, err)
+			continue
+		}
+		var fields []string
+		for _, field := range validationErr.Fields {
+			fields = append(fields, field.Field)
+		}
+		if strings.Join(fields, ",") != strings.Join(test.invalid, ",") {
+			t.Errorf("%s: invalid fields %v, want %v", test.name, fields, test.invalid)
+		}
+	}
+}
+
+func TestInvalidValuesAreNotSent(t *testing.T) {
+
+	server := newJenkins(false)
+	defer server.Close()
+	backend := &ScriptlerBackend{Client: newClient(t, server, Auth{Username: server.Username, Password: server.Password})}
+
+	_, err := CreateDockerTemplate(backend, "docker", DockerTemplate{Label: "TeamB&label=TeamA", Image: "example/slave:1.0"})
+	if _, ok := err.(*ValidationError); !ok {
+		t.Errorf("got %v, want a *ValidationError", err)
+	}
+	if _, err := RemoveDockerTemplate(backend, "docker", "TeamA linux"); err == nil {
+		t.Error("label with a space: expected an error")
+	}
+	if n := len(server.Requests()); n != 0 {
+		t.Errorf("%d requests sent for invalid values", n)
+	}
+
+	// the values that pass are escaped on the way
+	if created, err := CreateDockerTemplate(backend, "docker", DockerTemplate{Label: "TeamB", Image: "registry.example.com:5000/slave:1.0"}); err != nil || !created {
+		t.Fatalf("created %v, %v", created, err)
+	}
+	if image := server.Templates("docker")["TeamB"]; image != "registry.example.com:5000/slave:1.0" {
+		t.Errorf("image %q", image)
+	}
+}
@@ -1,273 +1,175 @@
 package main
 
 import (
-	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/bndr/gojenkins"
-	"github.com/docker/docker/api/types/container"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"github.com/stevebargelt/Dockhand/config"
 	"github.com/stevebargelt/Dockhand/docker"
 	"github.com/stevebargelt/Dockhand/jenkins"
+	"github.com/stevebargelt/Dockhand/secrets"
 )
 
-var (
-	dockerHostURL    = flag.String("dockerurl", "tcp://abs.harebrained-apps.com:2376", "the full address to the docker Jenkins host: tcp://<address>:<port>")
-	dockerTLSFolder  = flag.String("dockertlsfolder", "/users/steve/tlsBuild/", "Path to PEM encoded certificate, Key and CA for secure Docker TLS communication")
-	certFile         = flag.String("cert", "/users/steve/tlsBuild/cert.pem", "Path to a PEM encoded certificate file.")
-	keyFile          = flag.String("key", "/users/steve/tlsBuild/key.pem", "Path to a PEM encoded private key file.")
-	caFile           = flag.String("CA", "/users/steve/tlsBuild/ca.pem", "Path to a PEM encoded CA certificate file.")
-	registryURL      = flag.String("registry", "https://abs-registry.harebrained-apps.com", "The URL of the registry of where to find the image we are testing.")
-	registryUser     = flag.String("registryuser", "absadmin", "A user with rights to the registry we are pulling the test image from.")
-	registryPassword = flag.String("registrypassword", "correcthorsebatteystaple", "The password of the registry user")
-	imageName        = flag.String("imagename", "dockerbuild.harebrained-apps.com/jenkins-slavedotnet", "The name of the image we are testing.")
-	cloudName        = flag.String("cloudname", "AzureJenkins", "The name of the cloud configuration in Jenkins to use.")
-	label            = flag.String("label", "TeamBargelt_DotNetCore23", "The name of the label to use in Jenkins")
-	jenkinsURL       = flag.String("jenkinsurl", "http://dockerbuild.harebrained-apps.com", "The URL of the Jenkins Master.")
-	jenkinsUser      = flag.String("jenkinsuser", "stevebargelt", "A user with rights to the registry we are pulling the test image from.")
-	jenkinsPassword  = flag.String("jenkinspassword", "correcthorsebatteystaple", "The password of the registry user")
-	repoURL          = flag.String("repourl", "https://github.com/stevebargelt/simpleDotNet.git", "The repo url.")
-	configFile       = flag.String("config", "dockhand.yml", "A config file to use.")
+// flags maps each command line flag onto the config key it overrides.
+// Passwords have no flags: set them in the config file as secret:// references.
+var flags = []struct {
+	name  string
+	key   string
+	value interface{}
+	usage string
+}{
+	{"dockerurl", "dockerHostURL", "", "the full address to the docker Jenkins host: tcp://<address>:<port> or unix:///var/run/docker.sock (DOCKER_HOST or the local daemon when empty)"},
+	{"dockertlsfolder", "dockerTLSFolder", "", "Path to PEM encoded certificate, Key and CA for secure Docker TLS communication (DOCKER_CERT_PATH when empty)"},
+	{"cert", "certFile", "", "Path to a PEM encoded certificate file."},
+	{"key", "keyFile", "", "Path to a PEM encoded private key file."},
+	{"CA", "caFile", "", "Path to a PEM encoded CA certificate file."},
+	{"tlsverify", "dockerTLSVerify", false, "Use TLS and verify the Docker host's certificate (set by DOCKER_TLS_VERIFY)."},
+	{"registry", "registryURL", "https://abs-registry.harebrained-apps.com", "The URL of the registry of where to find the image we are testing."},
+	{"registryuser", "registryUser", "absadmin", "A user with rights to the registry we are pulling the test image from."},
+	{"imagename", "imageName", "dockerbuild.harebrained-apps.com/jenkins-slavedotnet", "The name of the image we are testing."},
+	{"cloudname", "cloudName", "AzureJenkins", "The name of the cloud configuration in Jenkins to use."},
+	{"label", "label", "TeamBargelt_DotNetCore23", "The name of the label to use in Jenkins"},
+	{"jenkinsurl", "jenkinsURL", "http://dockerbuild.harebrained-apps.com", "The URL of the Jenkins Master."},
+	{"jenkinsuser", "jenkinsUser", "stevebargelt", "A user with rights to the registry we are pulling the test image from."},
+	{"repourl", "repoURL", "https://github.com/stevebargelt/simpleDotNet.git", "The repo url."},
+	{"context", "build.context", "", "The build context: a directory, a tar file (- for stdin) or a Git URL. Defaults to the repo url."},
+	{"dockerfile", "build.dockerfile", "", "The path of the Dockerfile within the build context."},
+	{"target", "build.target", "", "The stage of a multi-stage Dockerfile to build."},
+	{"build-arg", "build.args", []string{}, "Build arguments as KEY=VALUE."},
+	{"build-label", "build.labels", []string{}, "Extra image labels as KEY=VALUE."},
+	{"cache-from", "build.cacheFrom", []string{}, "Images to use as cache sources for the build."},
+	{"no-cache", "build.noCache", false, "Do not use the cache when building the image."},
+	{"pull", "build.pull", false, "Always pull newer versions of the base images when building."},
+	{"agent-handshake", "agent.handshake", false, "Prove the image can connect as a Jenkins agent when verifying it."},
+	{"artifacts", "artifacts", "artifacts", "The directory verify saves the test container logs and report in, per run ID. Empty to not save them."},
+	{"standards", "standards", defaultStandards, "The test spec with the company standards the image must meet."},
+}
 
-	dockerClient  *docker.Host
-	jenkinsClient *gojenkins.Jenkins
+// These are set by the makefile through -ldflags
+var (
+	VERSION = "dev"
+	COMMIT  = "unknown"
+	BRANCH  = "unknown"
 )
 
-func main() {
+var (
+	configFile = pflag.String("config", "dockhand.yaml", "A config file to use.")
+	allLabels  = pflag.Bool("all-labels", false, "Make cleanup remove the test containers of every label, not just the one of --label.")
 
-	var err error
+	cfg           *config.Config
+	dockerClient  *docker.Host
+	jenkinsClient *gojenkins.Jenkins
+	// jenkinsHTTP authenticates and adds the crumb to every request made to Jenkins
+	jenkinsHTTP *jenkins.Client
+	// jenkinsScripts runs dockhand's Groovy scripts that manage the docker slave templates
+	jenkinsScripts jenkins.Backend
 
-	//flag.Parse()
+	// pushedDigest is the digest of the image pushed by this run
+	pushedDigest string
 
-	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
-	pflag.Parse()
-	viper.BindPFlags(pflag.CommandLine)
+	// secretResolver resolves the secret:// references in the config when a command first needs them
+	secretResolver = secrets.NewResolver()
+)
 
-	fmt.Print("\n********************\n Docker Image and Container Verification Process\n********************\n")
-	connectToDockerHost()
+// command is a single dockhand subcommand
+type command struct {
+	name        string
+	description string
+	run         func() error
+}
 
-	err = dockerClient.BuildDockerImage(*imageName, *repoURL)
-	if err != nil {
-		panic(err)
-	}
+var commands = []command{
+	{"build", "Build the image from the repo on the Docker host", buildCommand},
+	{"push", "Push the image to the registry", pushCommand},
+	{"verify", "Pull the image and verify a test container created from it", verifyCommand},
+	{"register", "Create the Jenkins docker slave template and job for the label", registerCommand},
+	{"deregister", "Remove the Jenkins docker slave template and job for the label", deregisterCommand},
+	{"run", "build, push, verify and register in one go", runCommand},
+	{"cleanup", "Remove the test containers left behind for the label by interrupted runs, or for every label with --all-labels", cleanupCommand},
+	{"status", "Show the state of the image and label on Docker and Jenkins", statusCommand},
+	{"config show", "Print the effective config with secrets redacted", configShowCommand},
+	{"scripts", "Print the Groovy scripts to install in Scriptler when jenkinsScripts is scriptler", scriptsCommand},
+}
 
-	err = dockerClient.PushDockerImage(*imageName, *registryUser, *registryPassword, *registryURL)
-	if err != nil {
-		panic(err)
-	}
+func main() {
 
-	pullDockerImage()
-	newContainer, err := createDockerContainer()
-	if err != nil {
-		panic(err)
-	}
-	startDockerContainer(newContainer)
-	testResult, err := testDockerContainer(newContainer)
-	if err != nil {
-		panic(err)
-	}
-	if !testResult {
-		fmt.Println("test failed!")
-		fmt.Println("Need to handle this (still remove contianer but then quit)!")
+	for _, f := range flags {
+		switch value := f.value.(type) {
+		case bool:
+			pflag.Bool(f.name, value, f.usage)
+		case []string:
+			pflag.StringSlice(f.name, value, f.usage)
+		default:
+			pflag.String(f.name, value.(string), f.usage)
+		}
+		viper.BindPFlag(f.key, pflag.Lookup(f.name))
+	}
+	pflag.Usage = usage
+	pflag.Parse()
 
+	cmd := findCommand(strings.Join(pflag.Args(), " "))
+	if cmd == nil {
+		if pflag.NArg() > 0 {
+			fmt.Fprintln(os.Stderr, "Unknown command:", strings.Join(pflag.Args(), " "))
+		}
+		usage()
+		os.Exit(2)
 	}
-	removeDockerContainer(newContainer)
 
-	//TODO: If tests pass we want to pull the image to all hosts in SWARM to save time at build
-	//		(advice from Maxfield Stewart of Riot Games )
-
-	fmt.Print("\n\n********************\nAdd Build To Jenkins\n********************\n")
-
-	fmt.Print("Checking that label ", *label, " is unique...")
-	labelIsUnique, err := jenkins.CheckLabelIsUnique(*jenkinsURL, *cloudName, *label, *jenkinsUser, *jenkinsPassword)
+	var err error
+	cfg, err = config.Load(viper.GetViper(), *configFile, pflag.CommandLine.Changed("config"))
 	if err != nil {
-		panic(err)
-	}
-	if labelIsUnique {
-		fmt.Println(" it is unique, continuing.")
-	} else {
-		fmt.Println(" it is NOT unique! build labels mut be unique.")
-		fmt.Println("The label", *label, "is not unique in Jenkins at", *jenkinsURL, "cannot create this build.")
-		fmt.Println("exiting...")
+		fmt.Fprintln(stderr, "Error loading config:", err)
 		os.Exit(1)
 	}
-
-	fmt.Print("Creating docker slave template in ", *cloudName, "... ")
-	slaveTemplateCreated, err := jenkins.CreateDockerTemplate(*jenkinsURL, *cloudName, *label, *imageName, *jenkinsUser, *jenkinsPassword)
-	if err != nil {
-		panic(err)
-	}
-	if slaveTemplateCreated {
-		fmt.Println("success!")
-	} else {
-		fmt.Println("Failed. Exiting.")
-		os.Exit(1)
+	// config show is how you find out what is wrong with the config, so it always runs
+	if cmd.name != "config show" {
+		if err := cfg.Validate(); err != nil {
+			fmt.Fprintln(stderr, "Error:", err)
+			os.Exit(1)
+		}
 	}
 
-	fmt.Print("Connecting to Jenkins... ")
-	jenkinsClient, err := jenkins.InitClient(*jenkinsURL, *jenkinsUser, *jenkinsPassword)
-	if err != nil {
-		fmt.Println("failed. Exiting")
-		os.Exit(1)
-	}
-	if jenkinsClient == nil {
-		fmt.Println("Failed. Jenkins object is nil. Exiting.")
-		os.Exit(1)
+	handleSignals()
+	err = runWithCleanup(cmd)
+	if sig, ok := interrupted.Load().(os.Signal); ok {
+		fmt.Fprintln(stderr, "Interrupted.")
+		os.Exit(signalExitCode(sig))
 	}
-	fmt.Println("success. Connected.")
-	fmt.Print("Adding jenkins job... ")
-	//TODO: Move this to a config file...
-	configString := `<?xml version='1.0' encoding='UTF-8'?>
-<flow-definition plugin="workflow-job@2.6">
-  <actions/>
-  <description></description>
-  <keepDependencies>false</keepDependencies>
-  <properties>
-    <com.coravy.hudson.plugins.github.GithubProjectProperty plugin="github@1.21.1">
-      <projectUrl>` + *repoURL + `</projectUrl>
-      <displayName></displayName>
-    </com.coravy.hudson.plugins.github.GithubProjectProperty>
-    <org.jenkinsci.plugins.workflow.job.properties.PipelineTriggersJobProperty>
-      <triggers>
-        <hudson.triggers.TimerTrigger>
-          <spec>H */3 * * *</spec>
-        </hudson.triggers.TimerTrigger>
-        <com.cloudbees.jenkins.GitHubPushTrigger plugin="github@1.21.1">
-          <spec></spec>
-        </com.cloudbees.jenkins.GitHubPushTrigger>
-      </triggers>
-    </org.jenkinsci.plugins.workflow.job.properties.PipelineTriggersJobProperty>
-  </properties>
-  <definition class="org.jenkinsci.plugins.workflow.cps.CpsScmFlowDefinition" plugin="workflow-cps@2.17">
-    <scm class="hudson.plugins.git.GitSCM" plugin="git@3.0.0">
-      <configVersion>2</configVersion>
-      <userRemoteConfigs>
-        <hudson.plugins.git.UserRemoteConfig>
-          <url>` + *repoURL + `</url>
-        </hudson.plugins.git.UserRemoteConfig>
-      </userRemoteConfigs>
-      <branches>
-        <hudson.plugins.git.BranchSpec>
-          <name>*/master</name>
-        </hudson.plugins.git.BranchSpec>
-      </branches>
-      <doGenerateSubmoduleConfigurations>false</doGenerateSubmoduleConfigurations>
-      <submoduleCfg class="list"/>
-      <extensions/>
-    </scm>
-    <scriptPath>Jenkinsfile</scriptPath>
-  </definition>
-  <triggers/>
-</flow-definition>`
-
-	tempStr := *label + "_JOB"
-	newJob, err := jenkinsClient.CreateJob(configString, tempStr)
 	if err != nil {
-		panic(err)
-		//fmt.Println("failed. Exiting")
-		//os.Exit(1)
-	}
-	fmt.Println("success! Created", newJob.GetName(), ".")
-
-	fmt.Print("Kicking first build... ")
-	m := make(map[string]string)
-	jobResult, err := newJob.InvokeSimple(m)
-	if err != nil {
-		panic(err)
-	}
-	if jobResult == true {
-		fmt.Println("build Success.")
-	} else {
-		fmt.Println("build fail.")
-	}
-
-}
-
-func connectToDockerHost() {
-
-	var err error
-	fmt.Print("Connecting to dockerhost... ")
-	dockerClient, err = docker.New(*dockerHostURL, *dockerTLSFolder)
-	if err != nil {
-		panic(err)
-	}
-	if dockerClient != nil {
-		fmt.Println("success!")
-	} else {
-		fmt.Println("failed. client is nil. Exiting")
+		fmt.Fprintln(stderr, "Error:", err)
 		os.Exit(1)
 	}
-
-}
-
-func pullDockerImage() {
-
-	var err error
-
-	fmt.Print("Pulling ", *imageName, " from registry ", *registryURL)
-	newImage, err := dockerClient.GetDockerImage(*imageName, *registryUser, *registryPassword, *registryURL)
-	if err != nil {
-		panic(err)
-	}
-	fmt.Println(" success!\nPulled Image ID:", newImage.ID[7:19])
-
-}
-
-func createDockerContainer() (container.ContainerCreateCreatedBody, error) {
-
-	fmt.Print("Creating continer from ", *imageName, "...")
-	//TODO: unique value here for container name? Add GUID? Add LabelName?
-	//TODO: create process to kill all containers that start with DockhandTesting??
-	newContianer, err := dockerClient.CreateContainer(*imageName, "DockhandTesting"+*label)
-	if err != nil {
-		return *newContianer, err
-	}
-	fmt.Println(" success.\nContiner ID: ", newContianer.ID[0:11])
-	return *newContianer, nil
-
 }
 
-func startDockerContainer(container container.ContainerCreateCreatedBody) {
+// runWithCleanup runs cmd and removes the containers it left behind, even when it panics
+func runWithCleanup(cmd *command) error {
 
-	var err error
-	fmt.Print("Starting continer ", container.ID[0:11], "...")
-	err = dockerClient.StartContainer(container.ID)
-	if err != nil {
-		panic(err)
-	}
-	fmt.Println("success.")
+	defer cleanupOnExit()
+	return cmd.run()
 }
 
-func testDockerContainer(container container.ContainerCreateCreatedBody) (bool, error) {
+func findCommand(name string) *command {
 
-	//TODO: Write tests to make sure the container fits company standards
-	fmt.Print("Testing continer ", container.ID[0:11], "...")
-	containerInfo, err := dockerClient.ContainerInspect(container.ID)
-	if err != nil {
-		return false, err
-	}
-	// fmt.Println("CONTAINERINFO:")
-	// fmt.Println("Name:", containerInfo.Name)
-	// fmt.Println("Status:", containerInfo.State.Status)
-	// fmt.Println("Exit Code:", containerInfo.State.ExitCode)
-	if containerInfo.State.ExitCode != 0 {
-		fmt.Println("failed. Exit code must be 0.")
-		return false, nil
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
 	}
-	fmt.Println("success.")
-	return true, nil
+	return nil
 }
 
-func removeDockerContainer(container container.ContainerCreateCreatedBody) error {
+func usage() {
 
-	var err error
-	fmt.Print("Removing continer ", container.ID[0:11], "...")
-	err = dockerClient.ContainerRemove(container.ID)
-	if err != nil {
-		return err
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags] <command>\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", cmd.name, cmd.description)
 	}
-	fmt.Println("success.")
-	return nil
+	fmt.Fprint(os.Stderr, "\nFlags:\n")
+	pflag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\nEvery setting can also be given in the config file or as a %s_<KEY> environment variable.\n", config.EnvPrefix)
 }
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// runID identifies this run of dockhand: it is part of the test container names, recorded in
+// the labels of every container the run creates and prefixed to every line of output
+var runID = newRunID()
+
+// runStarted is when this run began, recorded in the container labels
+var runStarted = time.Now().UTC()
+
+// stdout and stderr prefix every line with the run ID so the output of concurrent runs can be told apart
+var (
+	stdout io.Writer = newPrefixWriter(os.Stdout, "["+runID+"] ")
+	stderr io.Writer = newPrefixWriter(os.Stderr, "["+runID+"] ")
+)
+
+// newRunID returns a short random ID, unique enough to tell runs for the same label apart
+func newRunID() string {
+
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		// fall back on the clock rather than fail the run over an ID
+		return fmt.Sprintf("%08x", uint32(time.Now().UnixNano()))
+	}
+	return hex.EncodeToString(id)
+}
+
+// prefixWriter writes prefix at the start of every line
+type prefixWriter struct {
+	mu        sync.Mutex
+	w         io.Writer
+	prefix    []byte
+	startLine bool
+}
+
+func newPrefixWriter(w io.Writer, prefix string) *prefixWriter {
+	return &prefixWriter{w: w, prefix: []byte(prefix), startLine: true}
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := len(b)
+	var out bytes.Buffer
+	for len(b) > 0 {
+		if p.startLine {
+			out.Write(p.prefix)
+			p.startLine = false
+		}
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			out.Write(b)
+			break
+		}
+		out.Write(b[:i+1])
+		b = b[i+1:]
+		p.startLine = true
+	}
+
+	if _, err := p.w.Write(out.Bytes()); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestPrefixWriter(t *testing.T) {
+
+	var out bytes.Buffer
+	w := newPrefixWriter(&out, "[run] ")
+
+	fmt.Fprint(w, "Connecting... ")
+	fmt.Fprintln(w, "success!")
+	fmt.Fprint(w, "two\nlines\n")
+	fmt.Fprint(w, "\n")
+
+	want := "[run] Connecting... success!\n[run] two\n[run] lines\n[run] \n"
+	if out.String() != want {
+		t.Errorf("output %q, want %q", out.String(), want)
+	}
+}
+
+func TestNewRunID(t *testing.T) {
+
+	a, b := newRunID(), newRunID()
+	if len(a) != 8 || a == b {
+		t.Errorf("run IDs %q and %q are not unique 8 character IDs", a, b)
+	}
+}
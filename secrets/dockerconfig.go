@@ -0,0 +1,150 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DockerConfig reads registry credentials the way the docker CLI stores them: from
+// credential helpers, the credential store or the auths section of ~/.docker/config.json.
+// The key is the registry host, optionally followed by #username to get the user name
+// instead of the password: secret://docker/registry.example.com#username
+type DockerConfig struct {
+	// Path of config.json, defaults to $DOCKER_CONFIG/config.json or ~/.docker/config.json
+	Path string
+}
+
+type dockerConfigFile struct {
+	Auths       map[string]dockerAuth `json:"auths"`
+	CredsStore  string                `json:"credsStore"`
+	CredHelpers map[string]string     `json:"credHelpers"`
+}
+
+type dockerAuth struct {
+	Auth string `json:"auth"`
+}
+
+// Secret returns the password (or user name) stored for the registry in key
+func (d *DockerConfig) Secret(key string) (string, error) {
+
+	host, field := key, "password"
+	if i := strings.Index(key, "#"); i >= 0 {
+		host, field = key[:i], key[i+1:]
+	}
+
+	username, password, err := d.Credentials(host)
+	if err != nil {
+		return "", err
+	}
+	switch field {
+	case "password":
+		return password, nil
+	case "username":
+		return username, nil
+	}
+	return "", errors.New("unknown docker credential field " + field + ": use username or password")
+}
+
+// Credentials returns the user name and password the docker CLI would use for registry
+func (d *DockerConfig) Credentials(registry string) (string, string, error) {
+
+	path := d.Path
+	if path == "" {
+		path = defaultDockerConfigPath()
+	}
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	var config dockerConfigFile
+	if err := json.Unmarshal(contents, &config); err != nil {
+		return "", "", errors.New("cannot parse " + path + ": " + err.Error())
+	}
+
+	host := registryHost(registry)
+
+	for server, helper := range config.CredHelpers {
+		if registryHost(server) == host {
+			return credentialHelper(helper, server)
+		}
+	}
+
+	for server, auth := range config.Auths {
+		if registryHost(server) != host {
+			continue
+		}
+		if auth.Auth == "" {
+			// credentials for this registry live in the credential store
+			break
+		}
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return "", "", errors.New("cannot decode auth for " + server + ": " + err.Error())
+		}
+		parts := strings.SplitN(string(decoded), ":", 2)
+		if len(parts) != 2 {
+			return "", "", errors.New("malformed auth for " + server)
+		}
+		return parts[0], parts[1], nil
+	}
+
+	if config.CredsStore != "" {
+		return credentialHelper(config.CredsStore, registry)
+	}
+	return "", "", errors.New("no docker credentials for " + registry + " in " + path)
+}
+
+// credentialHelper runs docker-credential-<helper> get for server
+func credentialHelper(helper, server string) (string, string, error) {
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(server)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stdout.String() + stderr.String())
+		return "", "", errors.New("docker-credential-" + helper + " get " + server + " failed: " + err.Error() + ": " + msg)
+	}
+
+	var creds struct {
+		Username string
+		Secret   string
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &creds); err != nil {
+		return "", "", errors.New("cannot parse the output of docker-credential-" + helper + ": " + err.Error())
+	}
+	return creds.Username, creds.Secret, nil
+}
+
+// registryHost reduces https://registry.example.com/v1/ and registry.example.com to the same host
+func registryHost(server string) string {
+
+	host := server
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+	if i := strings.Index(host, "/"); i >= 0 {
+		host = host[:i]
+	}
+	return strings.ToLower(host)
+}
+
+func defaultDockerConfigPath() string {
+
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+	home := os.Getenv("HOME")
+	if home == "" {
+		home = os.Getenv("USERPROFILE")
+	}
+	return filepath.Join(home, ".docker", "config.json")
+}
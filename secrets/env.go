@@ -0,0 +1,19 @@
+package secrets
+
+import (
+	"errors"
+	"os"
+)
+
+// Env reads secrets from environment variables: secret://env/REGISTRY_PASSWORD
+type Env struct{}
+
+// Secret returns the value of the environment variable named key
+func (Env) Secret(key string) (string, error) {
+
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return "", errors.New("environment variable " + key + " is not set")
+	}
+	return value, nil
+}
@@ -0,0 +1,36 @@
+package secrets
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultSecretsDir is where Docker and Kubernetes mount secrets by default
+const DefaultSecretsDir = "/run/secrets"
+
+// File reads secrets from files such as Docker or Kubernetes secret mounts: secret://file/registry_password.
+// Relative keys are read from Dir, absolute keys (secret://file//etc/dockhand/password) as given.
+type File struct {
+	Dir string
+}
+
+// Secret returns the contents of the file named key without its trailing newline
+func (f *File) Secret(key string) (string, error) {
+
+	path := key
+	if !filepath.IsAbs(key) {
+		clean := filepath.Clean(key)
+		if strings.HasPrefix(clean, "..") {
+			return "", errors.New("secret file " + key + " is outside " + f.Dir)
+		}
+		path = filepath.Join(f.Dir, clean)
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(contents), "\r\n"), nil
+}
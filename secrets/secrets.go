@@ -0,0 +1,66 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Scheme marks a config value as a reference to a secret rather than the secret itself.
+// References look like secret://<provider>/<key>, for example secret://env/REGISTRY_PASSWORD
+const Scheme = "secret://"
+
+// Provider looks up the value of a secret given its key
+type Provider interface {
+	Secret(key string) (string, error)
+}
+
+// Resolver turns secret:// references into their values using the registered providers
+type Resolver struct {
+	providers map[string]Provider
+}
+
+// NewResolver returns a Resolver with the env, file and docker providers registered
+func NewResolver() *Resolver {
+
+	r := &Resolver{providers: map[string]Provider{}}
+	r.Register("env", Env{})
+	r.Register("file", &File{Dir: DefaultSecretsDir})
+	r.Register("docker", &DockerConfig{})
+	return r
+}
+
+// Register makes a provider available to references as secret://<name>/...
+func (r *Resolver) Register(name string, p Provider) {
+	r.providers[name] = p
+}
+
+// IsReference reports whether value is a secret:// reference
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, Scheme)
+}
+
+// Resolve returns the secret a reference points at. Values that are not references are returned unchanged.
+func (r *Resolver) Resolve(value string) (string, error) {
+
+	if !IsReference(value) {
+		return value, nil
+	}
+
+	ref := strings.TrimPrefix(value, Scheme)
+	slash := strings.Index(ref, "/")
+	if slash <= 0 || slash == len(ref)-1 {
+		return "", errors.New("malformed secret reference " + value + ": expected " + Scheme + "<provider>/<key>")
+	}
+	name, key := ref[:slash], ref[slash+1:]
+
+	p, ok := r.providers[name]
+	if !ok {
+		return "", fmt.Errorf("unknown secret provider %q in %s", name, value)
+	}
+	secret, err := p.Secret(key)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve %s: %v", value, err)
+	}
+	return secret, nil
+}
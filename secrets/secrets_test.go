@@ -0,0 +1,189 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tempDir(t *testing.T) string {
+
+	dir, err := ioutil.TempDir("", "dockhand-secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestResolve(t *testing.T) {
+
+	os.Setenv("DOCKHAND_TEST_SECRET", "from-env")
+	defer os.Unsetenv("DOCKHAND_TEST_SECRET")
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "jenkins_password"), []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewResolver()
+	r.Register("file", &File{Dir: dir})
+
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"plain value", "plain value", false},
+		{"secret://env/DOCKHAND_TEST_SECRET", "from-env", false},
+		{"secret://env/DOCKHAND_TEST_UNSET", "", true},
+		{"secret://file/jenkins_password", "from-file", false},
+		{"secret://file/../jenkins_password", "", true},
+		{"secret://nope/key", "", true},
+		{"secret://env", "", true},
+		{"secret://env/", "", true},
+	}
+	for _, tt := range tests {
+		got, err := r.Resolve(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Resolve(%q): error %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Resolve(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDockerConfigAuths(t *testing.T) {
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	auth := base64.StdEncoding.EncodeToString([]byte("absadmin:s3cr:et"))
+	config := `{"auths": {"https://registry.example.com/v1/": {"auth": "` + auth + `"}}}`
+	path := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &DockerConfig{Path: path}
+	if got, err := d.Secret("registry.example.com"); err != nil || got != "s3cr:et" {
+		t.Errorf("password: got %q, %v", got, err)
+	}
+	if got, err := d.Secret("REGISTRY.example.com#username"); err != nil || got != "absadmin" {
+		t.Errorf("username: got %q, %v", got, err)
+	}
+	if _, err := d.Secret("other.example.com"); err == nil {
+		t.Error("expected an error for a registry without credentials")
+	}
+}
+
+func TestDockerConfigCredentialHelper(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("credential helper stub is a shell script")
+	}
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	helper := "#!/bin/sh\nread server\necho '{\"ServerURL\":\"'$server'\",\"Username\":\"helperuser\",\"Secret\":\"helpersecret\"}'\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "docker-credential-dockhandtest"), []byte(helper), 0700); err != nil {
+		t.Fatal(err)
+	}
+	oldPath := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+oldPath)
+	defer os.Setenv("PATH", oldPath)
+
+	path := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(path, []byte(`{"credHelpers": {"registry.example.com": "dockhandtest"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &DockerConfig{Path: path}
+	username, password, err := d.Credentials("registry.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "helperuser" || password != "helpersecret" {
+		t.Errorf("got %q/%q", username, password)
+	}
+}
+
+func TestVault(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/dockhand":
+			w.Write([]byte(`{"data":{"registryPassword":"kv1-secret"}}`))
+		case "/v1/secret/data/dockhand":
+			w.Write([]byte(`{"data":{"data":{"registryPassword":"kv2-secret"},"metadata":{"version":3}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	v := NewVault(server.URL+"/", "root")
+	tests := []struct {
+		key     string
+		want    string
+		wantErr string
+	}{
+		{"secret/dockhand#registryPassword", "kv1-secret", ""},
+		{"secret/data/dockhand#registryPassword", "kv2-secret", ""},
+		{"secret/data/dockhand#jenkinsPassword", "", "no field"},
+		{"secret/missing#registryPassword", "", "404"},
+		{"secret/dockhand", "", "<path>#<field>"},
+	}
+	for _, tt := range tests {
+		got, err := v.Secret(tt.key)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Secret(%q): error %v, want it to mention %q", tt.key, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Secret(%q) = %q, %v; want %q", tt.key, got, err, tt.want)
+		}
+	}
+
+	denied := NewVault(server.URL, "wrong")
+	if _, err := denied.Secret("secret/dockhand#registryPassword"); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("expected permission denied, got %v", err)
+	}
+}
+
+func TestVaultTimeout(t *testing.T) {
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	v := NewVault(server.URL, "root")
+	if v.Client.Timeout != vaultTimeout {
+		t.Errorf("client timeout %s, want %s", v.Client.Timeout, vaultTimeout)
+	}
+	v.Client.Timeout = 50 * time.Millisecond
+	if _, err := v.Secret("secret/dockhand#registryPassword"); err == nil {
+		t.Error("expected a hanging vault to time out")
+	}
+}
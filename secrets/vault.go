@@ -0,0 +1,82 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Vault reads secrets from a HashiCorp Vault compatible HTTP API. The key is the secret path
+// and the field to return: secret://vault/secret/data/dockhand#registryPassword.
+// Both KV version 1 and version 2 (data nested under data) responses are understood.
+type Vault struct {
+	Address string
+	Token   string
+	Client  *http.Client
+}
+
+// vaultTimeout is how long reading a secret may take, so an unreachable Vault fails the run
+// instead of hanging it
+const vaultTimeout = 30 * time.Second
+
+// NewVault returns a Vault provider for the server at address
+func NewVault(address, token string) *Vault {
+	return &Vault{Address: strings.TrimSuffix(address, "/"), Token: token, Client: &http.Client{Timeout: vaultTimeout}}
+}
+
+// Secret returns the field of the secret at the path in key
+func (v *Vault) Secret(key string) (string, error) {
+
+	i := strings.LastIndex(key, "#")
+	if i <= 0 || i == len(key)-1 {
+		return "", errors.New("vault secret " + key + " must be of the form <path>#<field>")
+	}
+	path, field := strings.TrimPrefix(key[:i], "/"), key[i+1:]
+
+	url := v.Address + "/v1/" + path
+	r, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	r.Header.Set("X-Vault-Token", v.Token)
+
+	response, err := v.Client.Do(r)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	var body struct {
+		Data   map[string]interface{} `json:"data"`
+		Errors []string               `json:"errors"`
+	}
+	decodeErr := json.NewDecoder(response.Body).Decode(&body)
+
+	if response.StatusCode != 200 {
+		msg := "vault returned " + strconv.Itoa(response.StatusCode) + " for " + url
+		if len(body.Errors) > 0 {
+			msg += ": " + strings.Join(body.Errors, ", ")
+		}
+		return "", errors.New(msg)
+	}
+	if decodeErr != nil {
+		return "", errors.New("cannot parse the vault response for " + url + ": " + decodeErr.Error())
+	}
+
+	data := body.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+	value, ok := data[field]
+	if !ok {
+		return "", errors.New("vault secret " + path + " has no field " + field)
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", errors.New("vault secret " + path + " field " + field + " is not a string")
+	}
+	return s, nil
+}
@@ -0,0 +1,214 @@
+package standards
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Jenkins agent types
+const (
+	AgentSSH  = "ssh"
+	AgentJNLP = "jnlp"
+)
+
+// AgentOptions says what the image needs to work as a Jenkins docker slave
+type AgentOptions struct {
+	// Type is AgentSSH or AgentJNLP, either will do when it is empty
+	Type string
+	// MinJavaVersion and MaxJavaVersion bound the major version of the Java runtime, 0 means no bound
+	MinJavaVersion int
+	MaxJavaVersion int
+	// User and Home are the user the agent runs as and its home directory
+	User string
+	Home string
+}
+
+// AgentInfo is what the agent checks found out about the image
+type AgentInfo struct {
+	// Type is the agent type the image supports, empty when it supports none
+	Type        string
+	JavaVersion int
+	// SSHD is the path of the sshd binary
+	SSHD string
+	// Launcher is the path of the JNLP agent jar or the script that starts it
+	Launcher string
+}
+
+// findLaunchers lists the sshd binary and the JNLP agent launchers the image has
+const findLaunchers = `for f in /usr/sbin/sshd $(command -v sshd 2>/dev/null); do
+	if [ -x "$f" ]; then echo "ssh $f"; break; fi
+done
+for f in $(command -v jenkins-agent jenkins-slave 2>/dev/null) /usr/share/jenkins/agent.jar /usr/share/jenkins/slave.jar; do
+	if [ -e "$f" ]; then echo "jnlp $f"; fi
+done
+exit 0`
+
+// homeDirectory prints the home directory of a user and who owns it
+const homeDirectory = `home=$(grep "^$1:" /etc/passwd | cut -d: -f6)
+[ -n "$home" ] || exit 1
+echo "$home|$(stat -c %U "$home")"`
+
+var javaVersion = regexp.MustCompile(`version "(\d+)(?:\.(\d+))?`)
+
+// CheckAgent checks that the image can act as a Jenkins docker slave and adds the results to report
+func CheckAgent(options AgentOptions, c Container, report *Report) AgentInfo {
+
+	info := AgentInfo{}
+	info.JavaVersion = checkJava(options, c, report)
+	checkLaunchers(options, c, report, &info)
+
+	if options.User != "" {
+		checkAgentUser(options, c, report)
+	}
+
+	name := "agent git"
+	result, err := c.Run([]string{"git", "--version"}, RunOptions{})
+	switch {
+	case err != nil:
+		report.Add(name, false, "cannot run git: %v", err)
+	case result.ExitCode != 0:
+		report.Add(name, false, "git is not installed: %s", abbreviate(result.Stdout+result.Stderr))
+	default:
+		report.Add(name, true, "%s", strings.TrimSpace(result.Stdout))
+	}
+
+	return info
+}
+
+// checkJava returns the major version of the Java runtime, 0 if there is none
+func checkJava(options AgentOptions, c Container, report *Report) int {
+
+	name := "agent java"
+	result, err := c.Run([]string{"java", "-version"}, RunOptions{})
+	if err != nil {
+		report.Add(name, false, "cannot run java: %v", err)
+		return 0
+	}
+	output := result.Stdout + result.Stderr
+	if result.ExitCode != 0 {
+		report.Add(name, false, "no Java runtime: %s", abbreviate(output))
+		return 0
+	}
+	version := javaMajorVersion(output)
+	switch {
+	case version == 0:
+		report.Add(name, false, "cannot tell the Java version from %q", abbreviate(output))
+	case options.MinJavaVersion > 0 && version < options.MinJavaVersion:
+		report.Add(name, false, "Java %d, at least %d is needed", version, options.MinJavaVersion)
+	case options.MaxJavaVersion > 0 && version > options.MaxJavaVersion:
+		report.Add(name, false, "Java %d, at most %d is supported", version, options.MaxJavaVersion)
+	default:
+		report.Add(name, true, "Java %d", version)
+	}
+	return version
+}
+
+// javaMajorVersion reads the major version from java -version output,
+// 1.8.0_131 is Java 8 and 11.0.2 is Java 11
+func javaMajorVersion(output string) int {
+
+	match := javaVersion.FindStringSubmatch(output)
+	if match == nil {
+		return 0
+	}
+	major, _ := strconv.Atoi(match[1])
+	if major == 1 && match[2] != "" {
+		major, _ = strconv.Atoi(match[2])
+	}
+	return major
+}
+
+func checkLaunchers(options AgentOptions, c Container, report *Report, info *AgentInfo) {
+
+	name := "agent entrypoint"
+	result, err := c.Run([]string{"sh", "-c", findLaunchers}, RunOptions{})
+	if err != nil {
+		report.Add(name, false, "cannot look for sshd or a JNLP agent: %v", err)
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSpace(result.Stdout), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		switch {
+		case fields[0] == AgentSSH && info.SSHD == "":
+			info.SSHD = fields[1]
+		case fields[0] == AgentJNLP && info.Launcher == "":
+			info.Launcher = fields[1]
+		}
+	}
+
+	// the docker plugin starts ssh slaves with the image's own command, which has to run sshd
+	config, err := c.Config()
+	startsSSHD := err == nil && strings.Contains(strings.Join(append(config.Entrypoint, config.Cmd...), " "), "sshd")
+
+	ssh := info.SSHD != "" && startsSSHD
+	jnlp := info.Launcher != ""
+	switch options.Type {
+	case AgentSSH:
+		if ssh {
+			info.Type = AgentSSH
+			report.Add(name, true, "sshd %s", info.SSHD)
+		} else if info.SSHD == "" {
+			report.Add(name, false, "sshd is not installed")
+		} else {
+			report.Add(name, false, "the image does not start sshd")
+		}
+	case AgentJNLP:
+		if jnlp {
+			info.Type = AgentJNLP
+			report.Add(name, true, "JNLP agent %s", info.Launcher)
+		} else {
+			report.Add(name, false, "no JNLP agent jar or launcher script")
+		}
+	default:
+		switch {
+		case ssh:
+			info.Type = AgentSSH
+			report.Add(name, true, "sshd %s", info.SSHD)
+		case jnlp:
+			info.Type = AgentJNLP
+			report.Add(name, true, "JNLP agent %s", info.Launcher)
+		default:
+			report.Add(name, false, "neither sshd nor a JNLP agent")
+		}
+	}
+}
+
+func checkAgentUser(options AgentOptions, c Container, report *Report) {
+
+	name := "agent user " + options.User
+	result, err := c.Run([]string{"id", "-u", options.User}, RunOptions{})
+	switch {
+	case err != nil:
+		report.Add(name, false, "cannot run id: %v", err)
+		return
+	case result.ExitCode != 0:
+		report.Add(name, false, "no such user")
+		return
+	}
+	report.Add(name, true, "uid %s", strings.TrimSpace(result.Stdout))
+
+	if options.Home == "" {
+		return
+	}
+	name = "agent home " + options.Home
+	result, err = c.Run([]string{"sh", "-c", homeDirectory, "sh", options.User}, RunOptions{})
+	if err != nil {
+		report.Add(name, false, "cannot look up the home directory: %v", err)
+		return
+	}
+	fields := strings.Split(strings.TrimSpace(result.Stdout), "|")
+	switch {
+	case result.ExitCode != 0 || len(fields) != 2:
+		report.Add(name, false, "cannot look up the home directory of %s: %s", options.User, abbreviate(result.Stderr))
+	case fields[0] != options.Home:
+		report.Add(name, false, "the home directory of %s is %s", options.User, fields[0])
+	case fields[1] != options.User:
+		report.Add(name, false, "owned by %s", fields[1])
+	default:
+		report.Add(name, true, "")
+	}
+}
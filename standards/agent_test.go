@@ -0,0 +1,159 @@
+package standards
+
+import (
+	"strings"
+	"testing"
+)
+
+// scriptedContainer answers commands by their command line and the agent scripts by name
+type scriptedContainer struct {
+	config  ContainerConfig
+	answers map[string]CommandResult
+}
+
+func (c *scriptedContainer) Config() (ContainerConfig, error) {
+	return c.config, nil
+}
+
+func (c *scriptedContainer) Run(cmd []string, options RunOptions) (CommandResult, error) {
+
+	key := strings.Join(cmd, " ")
+	switch {
+	case len(cmd) > 2 && cmd[0] == "sh" && cmd[2] == findLaunchers:
+		key = "launchers"
+	case len(cmd) > 2 && cmd[0] == "sh" && cmd[2] == homeDirectory:
+		key = "home " + cmd[4]
+	}
+	return c.answers[key], nil
+}
+
+func TestJavaMajorVersion(t *testing.T) {
+
+	tests := []struct {
+		output string
+		want   int
+	}{
+		{`openjdk version "1.8.0_131"`, 8},
+		{`java version "1.7.0_80"`, 7},
+		{`openjdk version "11.0.2" 2019-01-15`, 11},
+		{`openjdk version "9"`, 9},
+		{`sh: java: not found`, 0},
+	}
+	for _, test := range tests {
+		if got := javaMajorVersion(test.output); got != test.want {
+			t.Errorf("javaMajorVersion(%q) = %d, want %d", test.output, got, test.want)
+		}
+	}
+}
+
+func TestCheckAgent(t *testing.T) {
+
+	sshSlave := func() *scriptedContainer {
+		return &scriptedContainer{
+			config: ContainerConfig{Cmd: []string{"/usr/sbin/sshd", "-D"}},
+			answers: map[string]CommandResult{
+				"java -version": {Stderr: `openjdk version "1.8.0_131"`},
+				"launchers":     {Stdout: "ssh /usr/sbin/sshd\n"},
+				"id -u jenkins": {Stdout: "1000\n"},
+				"home jenkins":  {Stdout: "/home/jenkins|jenkins\n"},
+				"git --version": {Stdout: "git version 2.11.0\n"},
+			},
+		}
+	}
+	options := AgentOptions{MinJavaVersion: 8, User: "jenkins", Home: "/home/jenkins"}
+
+	tests := []struct {
+		name     string
+		options  func(AgentOptions) AgentOptions
+		change   func(*scriptedContainer)
+		wantType string
+		failed   []string
+	}{
+		{
+			name:     "ssh slave",
+			wantType: AgentSSH,
+		},
+		{
+			name: "jnlp slave",
+			change: func(c *scriptedContainer) {
+				c.config.Cmd = []string{"/bin/sh"}
+				c.answers["launchers"] = CommandResult{Stdout: "ssh /usr/sbin/sshd\njnlp /usr/local/bin/jenkins-slave\njnlp /usr/share/jenkins/slave.jar\n"}
+			},
+			wantType: AgentJNLP,
+		},
+		{
+			name:    "ssh required but not started",
+			options: func(o AgentOptions) AgentOptions { o.Type = AgentSSH; return o },
+			change: func(c *scriptedContainer) {
+				c.config.Cmd = []string{"/bin/sh"}
+			},
+			failed: []string{"agent entrypoint"},
+		},
+		{
+			name:    "jnlp required",
+			options: func(o AgentOptions) AgentOptions { o.Type = AgentJNLP; return o },
+			failed:  []string{"agent entrypoint"},
+		},
+		{
+			name: "old java and no git",
+			change: func(c *scriptedContainer) {
+				c.answers["java -version"] = CommandResult{Stderr: `java version "1.7.0_80"`}
+				c.answers["git --version"] = CommandResult{ExitCode: 127, Stderr: "sh: git: not found"}
+			},
+			wantType: AgentSSH,
+			failed:   []string{"agent java", "agent git"},
+		},
+		{
+			name:    "java too new",
+			options: func(o AgentOptions) AgentOptions { o.MaxJavaVersion = 8; return o },
+			change: func(c *scriptedContainer) {
+				c.answers["java -version"] = CommandResult{Stderr: `openjdk version "11.0.2"`}
+			},
+			wantType: AgentSSH,
+			failed:   []string{"agent java"},
+		},
+		{
+			name: "wrong home",
+			change: func(c *scriptedContainer) {
+				c.answers["home jenkins"] = CommandResult{Stdout: "/var/jenkins_home|jenkins\n"}
+			},
+			wantType: AgentSSH,
+			failed:   []string{"agent home /home/jenkins"},
+		},
+		{
+			name: "no jenkins user",
+			change: func(c *scriptedContainer) {
+				c.answers["id -u jenkins"] = CommandResult{ExitCode: 1, Stderr: "id: jenkins: no such user"}
+			},
+			wantType: AgentSSH,
+			failed:   []string{"agent user jenkins"},
+		},
+	}
+
+	for _, test := range tests {
+		c := sshSlave()
+		if test.change != nil {
+			test.change(c)
+		}
+		o := options
+		if test.options != nil {
+			o = test.options(o)
+		}
+
+		report := &Report{}
+		info := CheckAgent(o, c, report)
+		if info.Type != test.wantType {
+			t.Errorf("%s: type %q, want %q", test.name, info.Type, test.wantType)
+		}
+
+		var failed []string
+		for _, result := range report.Results {
+			if !result.Passed {
+				failed = append(failed, result.Check)
+			}
+		}
+		if strings.Join(failed, ",") != strings.Join(test.failed, ",") {
+			t.Errorf("%s: failed checks %v, want %v (%+v)", test.name, failed, test.failed, report.Results)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package standards
+
+import (
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Spec declares what a candidate image must look like to meet company standards
+type Spec struct {
+	Commands     []CommandCheck `yaml:"commands"`
+	Files        []FileCheck    `yaml:"files"`
+	Env          []EnvCheck     `yaml:"env"`
+	Labels       []LabelCheck   `yaml:"labels"`
+	ExposedPorts []string       `yaml:"exposedPorts"`
+	NonRoot      bool           `yaml:"nonRoot"`
+	Packages     []PackageCheck `yaml:"packages"`
+}
+
+// CommandCheck runs a command and checks its exit code and output.
+// ExpectedOutput and ExcludedOutput are regular expressions matched against stdout and stderr combined.
+// Env (KEY=VALUE), User and Workdir default to the container's own.
+type CommandCheck struct {
+	Name           string   `yaml:"name"`
+	Command        []string `yaml:"command"`
+	Env            []string `yaml:"env"`
+	User           string   `yaml:"user"`
+	Workdir        string   `yaml:"workdir"`
+	ExitCode       int      `yaml:"exitCode"`
+	ExpectedOutput []string `yaml:"expectedOutput"`
+	ExcludedOutput []string `yaml:"excludedOutput"`
+}
+
+// FileCheck checks that a file or directory exists. Permissions is either symbolic
+// (drwxr-xr-x) or octal (0755); Owner is a user name. Empty fields are not checked.
+type FileCheck struct {
+	Path        string `yaml:"path"`
+	IsDir       bool   `yaml:"isDir"`
+	Permissions string `yaml:"permissions"`
+	Owner       string `yaml:"owner"`
+}
+
+// EnvCheck checks that the image sets an environment variable, optionally to a value matching a regular expression
+type EnvCheck struct {
+	Key   string `yaml:"key"`
+	Value string `yaml:"value"`
+}
+
+// LabelCheck checks that the image has a label, optionally with an exact value
+type LabelCheck struct {
+	Key   string `yaml:"key"`
+	Value string `yaml:"value"`
+}
+
+// PackageCheck checks that a package is installed (dpkg, rpm or apk), optionally with a version matching a regular expression
+type PackageCheck struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+}
+
+// LoadSpec reads a Spec from a YAML file
+func LoadSpec(path string) (*Spec, error) {
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var spec Spec
+	if err := yaml.Unmarshal(contents, &spec); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
@@ -0,0 +1,332 @@
+package standards
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Container is the candidate container the checks run against
+type Container interface {
+	// Config returns the configuration the container was created with
+	Config() (ContainerConfig, error)
+	// Run runs cmd in the container
+	Run(cmd []string, options RunOptions) (CommandResult, error)
+}
+
+// RunOptions change how a command runs, empty fields keep the container's own settings
+type RunOptions struct {
+	// Env are extra KEY=VALUE environment variables
+	Env     []string
+	User    string
+	Workdir string
+}
+
+// ContainerConfig is the part of the container configuration the checks look at
+type ContainerConfig struct {
+	User         string
+	Entrypoint   []string
+	Cmd          []string
+	Env          []string
+	Labels       map[string]string
+	ExposedPorts []string
+}
+
+// CommandResult is the outcome of running a command in the container
+type CommandResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// Result is the outcome of a single check
+type Result struct {
+	Check   string
+	Passed  bool
+	Message string
+}
+
+// Report collects the results of all checks
+type Report struct {
+	Results []Result
+}
+
+// Add records the result of a check
+func (r *Report) Add(check string, passed bool, format string, args ...interface{}) {
+	r.Results = append(r.Results, Result{Check: check, Passed: passed, Message: fmt.Sprintf(format, args...)})
+}
+
+// Passed reports whether every check passed
+func (r *Report) Passed() bool {
+	return r.Failures() == 0
+}
+
+// Failures returns the number of failed checks
+func (r *Report) Failures() int {
+
+	failures := 0
+	for _, result := range r.Results {
+		if !result.Passed {
+			failures++
+		}
+	}
+	return failures
+}
+
+// Print writes one line per check and a summary to w
+func (r *Report) Print(w io.Writer) {
+
+	for _, result := range r.Results {
+		status := "PASS"
+		if !result.Passed {
+			status = "FAIL"
+		}
+		fmt.Fprintf(w, "  [%s] %s", status, result.Check)
+		if result.Message != "" {
+			fmt.Fprintf(w, ": %s", result.Message)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%d checks, %d failed\n", len(r.Results), r.Failures())
+}
+
+// Run runs every check in spec against c and adds the results to report
+func Run(spec *Spec, c Container, report *Report) {
+
+	config, err := c.Config()
+	if err != nil {
+		report.Add("container config", false, "cannot read the container config: %v", err)
+	} else {
+		checkEnv(spec.Env, config, report)
+		checkLabels(spec.Labels, config, report)
+		checkExposedPorts(spec.ExposedPorts, config, report)
+	}
+
+	if spec.NonRoot {
+		checkNonRoot(c, report)
+	}
+	for _, check := range spec.Commands {
+		checkCommand(check, c, report)
+	}
+	for _, check := range spec.Files {
+		checkFile(check, c, report)
+	}
+	if len(spec.Packages) > 0 {
+		checkPackages(spec.Packages, c, report)
+	}
+}
+
+func checkCommand(check CommandCheck, c Container, report *Report) {
+
+	name := check.Name
+	if name == "" {
+		name = strings.Join(check.Command, " ")
+	}
+	name = "command " + name
+
+	if len(check.Command) == 0 {
+		report.Add(name, false, "no command given")
+		return
+	}
+	result, err := c.Run(check.Command, RunOptions{Env: check.Env, User: check.User, Workdir: check.Workdir})
+	if err != nil {
+		report.Add(name, false, "cannot run: %v", err)
+		return
+	}
+
+	var problems []string
+	if result.ExitCode != check.ExitCode {
+		problems = append(problems, fmt.Sprintf("exit code %d, want %d", result.ExitCode, check.ExitCode))
+	}
+	output := result.Stdout + result.Stderr
+	for _, pattern := range check.ExpectedOutput {
+		if ok, err := regexp.MatchString(pattern, output); err != nil || !ok {
+			problems = append(problems, fmt.Sprintf("output does not match %q", pattern))
+		}
+	}
+	for _, pattern := range check.ExcludedOutput {
+		if ok, err := regexp.MatchString(pattern, output); err != nil || ok {
+			problems = append(problems, fmt.Sprintf("output matches excluded %q", pattern))
+		}
+	}
+
+	if len(problems) > 0 {
+		report.Add(name, false, "%s (output: %s)", strings.Join(problems, "; "), abbreviate(output))
+		return
+	}
+	report.Add(name, true, "")
+}
+
+func checkFile(check FileCheck, c Container, report *Report) {
+
+	name := "file " + check.Path
+	result, err := c.Run([]string{"stat", "-c", "%F|%A|%a|%U", check.Path}, RunOptions{})
+	if err != nil {
+		report.Add(name, false, "cannot run stat: %v", err)
+		return
+	}
+	if result.ExitCode != 0 {
+		report.Add(name, false, "does not exist")
+		return
+	}
+
+	fields := strings.Split(strings.TrimSpace(result.Stdout), "|")
+	if len(fields) != 4 {
+		report.Add(name, false, "unexpected stat output %q", result.Stdout)
+		return
+	}
+	fileType, symbolic, octal, owner := fields[0], fields[1], fields[2], fields[3]
+
+	var problems []string
+	if check.IsDir && fileType != "directory" {
+		problems = append(problems, "is a "+fileType+", not a directory")
+	}
+	if check.Permissions != "" {
+		want := check.Permissions
+		got := symbolic
+		if want[0] >= '0' && want[0] <= '7' {
+			want = strings.TrimLeft(want, "0")
+			got = strings.TrimLeft(octal, "0")
+		}
+		if got != want {
+			problems = append(problems, fmt.Sprintf("permissions %s, want %s", got, check.Permissions))
+		}
+	}
+	if check.Owner != "" && owner != check.Owner {
+		problems = append(problems, fmt.Sprintf("owned by %s, want %s", owner, check.Owner))
+	}
+
+	if len(problems) > 0 {
+		report.Add(name, false, "%s", strings.Join(problems, "; "))
+		return
+	}
+	report.Add(name, true, "")
+}
+
+func checkEnv(checks []EnvCheck, config ContainerConfig, report *Report) {
+
+	env := map[string]string{}
+	for _, kv := range config.Env {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) == 2 {
+			env[parts[0]] = parts[1]
+		}
+	}
+
+	for _, check := range checks {
+		name := "env " + check.Key
+		value, ok := env[check.Key]
+		switch {
+		case !ok:
+			report.Add(name, false, "not set")
+		case check.Value == "":
+			report.Add(name, true, "")
+		default:
+			matched, err := regexp.MatchString(check.Value, value)
+			if err != nil || !matched {
+				report.Add(name, false, "value %q does not match %q", value, check.Value)
+			} else {
+				report.Add(name, true, "")
+			}
+		}
+	}
+}
+
+func checkLabels(checks []LabelCheck, config ContainerConfig, report *Report) {
+
+	for _, check := range checks {
+		name := "label " + check.Key
+		value, ok := config.Labels[check.Key]
+		switch {
+		case !ok:
+			report.Add(name, false, "not set")
+		case check.Value != "" && value != check.Value:
+			report.Add(name, false, "value %q, want %q", value, check.Value)
+		default:
+			report.Add(name, true, "")
+		}
+	}
+}
+
+func checkExposedPorts(ports []string, config ContainerConfig, report *Report) {
+
+	exposed := map[string]bool{}
+	for _, port := range config.ExposedPorts {
+		exposed[normalizePort(port)] = true
+	}
+	for _, port := range ports {
+		name := "exposed port " + port
+		if exposed[normalizePort(port)] {
+			report.Add(name, true, "")
+		} else {
+			report.Add(name, false, "not exposed")
+		}
+	}
+}
+
+func normalizePort(port string) string {
+
+	if !strings.Contains(port, "/") {
+		return port + "/tcp"
+	}
+	return strings.ToLower(port)
+}
+
+func checkNonRoot(c Container, report *Report) {
+
+	result, err := c.Run([]string{"id", "-u"}, RunOptions{})
+	switch {
+	case err != nil:
+		report.Add("non-root user", false, "cannot run id: %v", err)
+	case result.ExitCode != 0:
+		report.Add("non-root user", false, "id -u failed: %s", abbreviate(result.Stderr))
+	case strings.TrimSpace(result.Stdout) == "0":
+		report.Add("non-root user", false, "the container runs as root")
+	default:
+		report.Add("non-root user", true, "uid %s", strings.TrimSpace(result.Stdout))
+	}
+}
+
+// packageVersion queries whichever package manager the image has
+const packageVersion = `name="$1"
+if command -v dpkg-query >/dev/null 2>&1; then dpkg-query -W -f='${Version}' "$name"
+elif command -v rpm >/dev/null 2>&1; then rpm -q --qf '%{VERSION}-%{RELEASE}' "$name"
+elif command -v apk >/dev/null 2>&1; then apk info -v 2>/dev/null | sed -n "s/^$name-\([0-9].*\)/\1/p" | grep .
+else echo "no supported package manager" >&2; exit 2
+fi`
+
+func checkPackages(checks []PackageCheck, c Container, report *Report) {
+
+	for _, check := range checks {
+		name := "package " + check.Name
+		result, err := c.Run([]string{"sh", "-c", packageVersion, "sh", check.Name}, RunOptions{})
+		if err != nil {
+			report.Add(name, false, "cannot query: %v", err)
+			continue
+		}
+		version := strings.TrimSpace(result.Stdout)
+		if result.ExitCode != 0 || version == "" {
+			report.Add(name, false, "not installed %s", abbreviate(result.Stderr))
+			continue
+		}
+		if check.Version != "" {
+			matched, err := regexp.MatchString(check.Version, version)
+			if err != nil || !matched {
+				report.Add(name, false, "version %s does not match %q", version, check.Version)
+				continue
+			}
+		}
+		report.Add(name, true, "version %s", version)
+	}
+}
+
+// abbreviate shortens command output for the report
+func abbreviate(s string) string {
+
+	s = strings.TrimSpace(s)
+	if len(s) > 200 {
+		s = s[:200] + "..."
+	}
+	return s
+}
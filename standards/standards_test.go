@@ -0,0 +1,248 @@
+package standards
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeContainer answers commands from a table keyed by the command line
+type fakeContainer struct {
+	config   ContainerConfig
+	commands map[string]CommandResult
+}
+
+func (c *fakeContainer) Config() (ContainerConfig, error) {
+	return c.config, nil
+}
+
+func (c *fakeContainer) Run(cmd []string, options RunOptions) (CommandResult, error) {
+
+	key := strings.Join(cmd, " ")
+	if cmd[0] == "sh" && len(cmd) == 5 {
+		// package queries pass the package name after the script
+		key = "package " + cmd[4]
+	}
+	result, ok := c.commands[key]
+	if !ok {
+		return CommandResult{}, errors.New("unexpected command " + key)
+	}
+	return result, nil
+}
+
+func newFakeContainer() *fakeContainer {
+
+	return &fakeContainer{
+		config: ContainerConfig{
+			User:         "jenkins",
+			Env:          []string{"PATH=/usr/local/bin:/usr/bin", "JAVA_HOME=/usr/lib/jvm/java-8"},
+			Labels:       map[string]string{"dockhand.team": "TeamBargelt"},
+			ExposedPorts: []string{"22/tcp"},
+		},
+		commands: map[string]CommandResult{
+			"java -version":                       {Stderr: `openjdk version "1.8.0_131"`},
+			"false":                               {ExitCode: 1},
+			"id -u":                               {Stdout: "1000\n"},
+			"stat -c %F|%A|%a|%U /home/jenkins":   {Stdout: "directory|drwxr-xr-x|755|jenkins\n"},
+			"stat -c %F|%A|%a|%U /etc/shadow":     {Stdout: "regular file|-rw-r-----|640|root\n"},
+			"stat -c %F|%A|%a|%U /does/not/exist": {Stderr: "stat: cannot stat '/does/not/exist'", ExitCode: 1},
+			"package git":                         {Stdout: "1:2.11.0-3"},
+			"package openjdk-8-jdk":               {Stdout: "8u131-b11-1"},
+			"package nano":                        {ExitCode: 1, Stderr: "no packages found matching nano"},
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+
+	spec := &Spec{
+		Commands: []CommandCheck{
+			{Name: "java", Command: []string{"java", "-version"}, ExpectedOutput: []string{`version "1\.8`}},
+			{Name: "java 9", Command: []string{"java", "-version"}, ExpectedOutput: []string{`version "9`}},
+			{Name: "no debug", Command: []string{"java", "-version"}, ExcludedOutput: []string{"openjdk"}},
+			{Command: []string{"false"}, ExitCode: 1},
+			{Command: []string{"false"}},
+		},
+		Files: []FileCheck{
+			{Path: "/home/jenkins", IsDir: true, Permissions: "0755", Owner: "jenkins"},
+			{Path: "/etc/shadow", Permissions: "-rw-r-----"},
+			{Path: "/etc/shadow", IsDir: true, Permissions: "600", Owner: "jenkins"},
+			{Path: "/does/not/exist"},
+		},
+		Env: []EnvCheck{
+			{Key: "JAVA_HOME"},
+			{Key: "PATH", Value: "/usr/local/bin"},
+			{Key: "PATH", Value: "^/opt"},
+			{Key: "MAVEN_HOME"},
+		},
+		Labels: []LabelCheck{
+			{Key: "dockhand.team", Value: "TeamBargelt"},
+			{Key: "dockhand.team", Value: "TeamOther"},
+			{Key: "dockhand.label"},
+		},
+		ExposedPorts: []string{"22", "8080/tcp"},
+		NonRoot:      true,
+		Packages: []PackageCheck{
+			{Name: "git"},
+			{Name: "openjdk-8-jdk", Version: "^8u"},
+			{Name: "openjdk-8-jdk", Version: "^9"},
+			{Name: "nano"},
+		},
+	}
+
+	report := &Report{}
+	Run(spec, newFakeContainer(), report)
+
+	want := []struct {
+		check  string
+		passed bool
+	}{
+		{"env JAVA_HOME", true},
+		{"env PATH", true},
+		{"env PATH", false},
+		{"env MAVEN_HOME", false},
+		{"label dockhand.team", true},
+		{"label dockhand.team", false},
+		{"label dockhand.label", false},
+		{"exposed port 22", true},
+		{"exposed port 8080/tcp", false},
+		{"non-root user", true},
+		{"command java", true},
+		{"command java 9", false},
+		{"command no debug", false},
+		{"command false", true},
+		{"command false", false},
+		{"file /home/jenkins", true},
+		{"file /etc/shadow", true},
+		{"file /etc/shadow", false},
+		{"file /does/not/exist", false},
+		{"package git", true},
+		{"package openjdk-8-jdk", true},
+		{"package openjdk-8-jdk", false},
+		{"package nano", false},
+	}
+
+	if len(report.Results) != len(want) {
+		t.Fatalf("got %d results, want %d: %+v", len(report.Results), len(want), report.Results)
+	}
+	for i, w := range want {
+		got := report.Results[i]
+		if got.Check != w.check || got.Passed != w.passed {
+			t.Errorf("result %d = %s passed=%v (%s), want %s passed=%v", i, got.Check, got.Passed, got.Message, w.check, w.passed)
+		}
+	}
+	if report.Passed() {
+		t.Error("report passed with failed checks")
+	}
+	if got, want := report.Failures(), 12; got != want {
+		t.Errorf("Failures() = %d, want %d", got, want)
+	}
+}
+
+func TestRunRoot(t *testing.T) {
+
+	c := newFakeContainer()
+	c.commands["id -u"] = CommandResult{Stdout: "0\n"}
+
+	report := &Report{}
+	Run(&Spec{NonRoot: true}, c, report)
+	if report.Passed() {
+		t.Errorf("a container running as root passed the non-root check: %+v", report.Results)
+	}
+}
+
+func TestReportPrint(t *testing.T) {
+
+	report := &Report{}
+	report.Add("env PATH", true, "")
+	report.Add("label dockhand.team", false, "not set")
+
+	var out bytes.Buffer
+	report.Print(&out)
+	want := "  [PASS] env PATH\n  [FAIL] label dockhand.team: not set\n2 checks, 1 failed\n"
+	if out.String() != want {
+		t.Errorf("Print() = %q, want %q", out.String(), want)
+	}
+}
+
+func TestLoadSpec(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "standards")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "standards.yaml")
+	contents := `commands:
+  - name: java
+    command: ["java", "-version"]
+    expectedOutput: ["1\\.8"]
+files:
+  - path: /home/jenkins
+    isDir: true
+    permissions: "0755"
+exposedPorts: ["22/tcp"]
+nonRoot: true
+packages:
+  - name: git
+    version: "^1:2"
+`
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	spec, err := LoadSpec(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spec.Commands) != 1 || spec.Commands[0].Command[1] != "-version" || spec.Commands[0].ExpectedOutput[0] != `1\.8` {
+		t.Errorf("commands = %+v", spec.Commands)
+	}
+	if len(spec.Files) != 1 || !spec.Files[0].IsDir || spec.Files[0].Permissions != "0755" {
+		t.Errorf("files = %+v", spec.Files)
+	}
+	if !spec.NonRoot || spec.ExposedPorts[0] != "22/tcp" || spec.Packages[0].Version != "^1:2" {
+		t.Errorf("spec = %+v", spec)
+	}
+
+	if _, err := LoadSpec(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("LoadSpec of a missing file succeeded")
+	}
+}
+
+// optionsContainer records the options commands are run with
+type optionsContainer struct {
+	fakeContainer
+	options []RunOptions
+}
+
+func (c *optionsContainer) Run(cmd []string, options RunOptions) (CommandResult, error) {
+
+	c.options = append(c.options, options)
+	return CommandResult{Stdout: "2.1.4"}, nil
+}
+
+func TestRunCommandOptions(t *testing.T) {
+
+	c := &optionsContainer{}
+	spec := &Spec{Commands: []CommandCheck{{
+		Command: []string{"dotnet", "--version"},
+		Env:     []string{"DOTNET_CLI_TELEMETRY_OPTOUT=1"},
+		User:    "jenkins",
+		Workdir: "/home/jenkins",
+	}}}
+	Run(spec, c, &Report{})
+
+	if len(c.options) != 1 {
+		t.Fatalf("ran %d commands, want 1", len(c.options))
+	}
+	got := c.options[0]
+	if got.User != "jenkins" || got.Workdir != "/home/jenkins" || len(got.Env) != 1 || got.Env[0] != "DOTNET_CLI_TELEMETRY_OPTOUT=1" {
+		t.Errorf("options = %+v", got)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stevebargelt/Dockhand/config"
+	"github.com/stevebargelt/Dockhand/docker"
+	"github.com/stevebargelt/Dockhand/docker/dockertest"
+	"golang.org/x/net/context"
+)
+
+const verifySpec = `commands:
+  - name: git
+    command: ["git", "--version"]
+    expectedOutput: ["^git version 2\\."]
+env:
+  - key: JAVA_HOME
+`
+
+// slave answers the commands the standards and agent checks run like an ssh slave image
+// with javaVersion installed does
+func slave(javaVersion string) func(cmd []string) dockertest.Result {
+
+	return func(cmd []string) dockertest.Result {
+		switch {
+		case cmd[0] == "java":
+			return dockertest.Result{Stderr: `openjdk version "` + javaVersion + `"`}
+		case cmd[0] == "git":
+			return dockertest.Result{Stdout: "git version 2.11.0\n"}
+		case cmd[0] == "sh" && strings.Contains(strings.Join(cmd, " "), "sshd"):
+			return dockertest.Result{Stdout: "ssh /usr/sbin/sshd\n"}
+		}
+		return dockertest.Result{Stderr: cmd[0] + ": not found", ExitCode: 127}
+	}
+}
+
+// verify runs the verify steps against fake for an image started with sshd
+func verify(t *testing.T, fake *dockertest.Client) (bool, string) {
+
+	dir, err := ioutil.TempDir("", "verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	spec := filepath.Join(dir, "standards.yaml")
+	if err := ioutil.WriteFile(spec, []byte(verifySpec), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.AddImage("dockhand/slave", nil)
+	dockerClient = docker.NewWithClient("fake", fake)
+	cfg = &config.Config{
+		ImageName:      "dockhand/slave",
+		Label:          "DotNetCore23",
+		Standards:      spec,
+		StartupWait:    1,
+		CommandTimeout: 10,
+		Container: config.ContainerConfig{
+			Cmd: []string{"/usr/sbin/sshd", "-D"},
+			Env: []string{"JAVA_HOME=/usr/lib/jvm/java-8-openjdk-amd64"},
+		},
+		Agent: config.AgentConfig{Verify: true, MinJavaVersion: 8},
+	}
+	var out bytes.Buffer
+	stdout = &out
+
+	created, err := createDockerContainer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer removeContainer(created.ID)
+	if err := startDockerContainer(created); err != nil {
+		t.Fatal(err)
+	}
+	passed, err := testDockerContainer(created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return passed, out.String()
+}
+
+func TestVerifyRunningContainer(t *testing.T) {
+
+	// the slave keeps running, so the checks exec into it
+	fake := dockertest.New("")
+	run := slave("1.8.0_131")
+	fake.Exec = func(c *dockertest.Container, cmd []string) dockertest.Result {
+		return run(cmd)
+	}
+
+	passed, out := verify(t, fake)
+	if !passed || strings.Contains(out, "[FAIL]") {
+		t.Errorf("verification failed:\n%s", out)
+	}
+	for _, check := range []string{"[PASS] container starts: still running", "[PASS] command git", "[PASS] env JAVA_HOME", "[PASS] agent java: Java 8", "[PASS] agent entrypoint"} {
+		if !strings.Contains(out, check) {
+			t.Errorf("no %q in:\n%s", check, out)
+		}
+	}
+}
+
+func TestVerifyExitedContainer(t *testing.T) {
+
+	// the container exits right away, so every check runs in a container of its own
+	fake := dockertest.New("")
+	run := slave("1.7.0_80")
+	fake.Run = func(c *dockertest.Container) dockertest.Result {
+		if c.Name != "" {
+			return dockertest.Result{}
+		}
+		return run(append(c.Config.Entrypoint, c.Config.Cmd...))
+	}
+
+	passed, out := verify(t, fake)
+	if passed {
+		t.Errorf("verification passed with Java 7:\n%s", out)
+	}
+	for _, check := range []string{"[PASS] container starts: exited with code 0", "[PASS] command git", "[FAIL] agent java: Java 7, at least 8 is needed"} {
+		if !strings.Contains(out, check) {
+			t.Errorf("no %q in:\n%s", check, out)
+		}
+	}
+	if containers, _ := fake.ContainerList(context.Background(), types.ContainerListOptions{All: true}); len(containers) != 0 {
+		t.Errorf("%d containers left behind", len(containers))
+	}
+}